@@ -0,0 +1,76 @@
+package fcf
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// conformanceCase is a document's fields in a form a protojson encoder
+// may produce, along with the same fields in the form Encode produces
+type conformanceCase struct {
+	Name      string                 `json:"name"`
+	Protojson map[string]interface{} `json:"protojson"`
+	Canonical map[string]interface{} `json:"canonical"`
+}
+
+func TestProtojsonConformance(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "protojson", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no conformance fixtures found")
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cases []conformanceCase
+		if err := json.Unmarshal(data, &cases); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		for _, c := range cases {
+			name := filepath.Base(file) + ": " + c.Name
+			got, err := roundTrip(c.Protojson)
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+			expected, err := roundTrip(c.Canonical)
+			if err != nil {
+				t.Errorf("%s: canonical form: %v", name, err)
+				continue
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("%s:\nexpected %v\ngot      %v", name, expected, got)
+			}
+			if !reflect.DeepEqual(expected, c.Canonical) {
+				t.Errorf("%s: canonical form is not what Encode produces:\nexpected %v\ngot      %v", name, c.Canonical, expected)
+			}
+		}
+	}
+}
+
+// roundTrip decodes fields dynamically and encodes the result
+// in a form that can be compared to JSON
+func roundTrip(fields map[string]interface{}) (map[string]interface{}, error) {
+	var decoded map[string]interface{}
+	if err := (Value{Fields: fields}).Decode(&decoded); err != nil {
+		return nil, err
+	}
+	encoded, err := Encode(decoded)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(encoded)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]interface{}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
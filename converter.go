@@ -0,0 +1,97 @@
+package fcf
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ConverterFunc converts a raw firestore value into a Go value.
+// raw is the value as it appears in the event, as it is passed to
+// Unmarshaler, and the result must be convertible to the Go type
+// the converter was registered for.
+type ConverterFunc func(raw interface{}) (interface{}, error)
+
+type converterKey struct {
+	fcfType string
+	goType  reflect.Type
+}
+
+var converters = struct {
+	sync.RWMutex
+	m map[converterKey]ConverterFunc
+}{m: map[converterKey]ConverterFunc{}}
+
+// RegisterConverter registers fn to decode firestore values of fcfType
+// (e.g. integerValue) into fields of goType and of pointer to goType.
+// Registered converters take precedence over Unmarshaler, UnmarshalText
+// and the built in conversions. A Decoder's own converters take
+// precedence over registered ones. RegisterConverter is safe
+// for concurrent use, but is usually called from an init function.
+func RegisterConverter(fcfType string, goType reflect.Type, fn ConverterFunc) {
+	converters.Lock()
+	defer converters.Unlock()
+	converters.m[converterKey{fcfType, goType}] = fn
+}
+
+// Converter registers fn to decode firestore values of fcfType into goType
+// for a single Decoder, as RegisterConverter does for all decoding
+func Converter(fcfType string, goType reflect.Type, fn ConverterFunc) Option {
+	return func(o *options) {
+		if o.converters == nil {
+			o.converters = map[converterKey]ConverterFunc{}
+		}
+		o.converters[converterKey{fcfType, goType}] = fn
+	}
+}
+
+func (d *Decoder) converter(fcfType string, goType reflect.Type) (ConverterFunc, bool) {
+	key := converterKey{fcfType, goType}
+	if fn, ok := d.converters[key]; ok {
+		return fn, true
+	}
+	converters.RLock()
+	defer converters.RUnlock()
+	fn, ok := converters.m[key]
+	return fn, ok
+}
+
+// convertCustom decodes the field with a registered converter,
+// if there is one for its type, and reports whether it did
+func (d *decodeState) convertCustom(f field) (bool, error) {
+	if f.FcfType() == "" {
+		// the raw members of a geoPointValue
+		return false, nil
+	}
+	fieldType := f.Type()
+	fn, ok := d.converter(f.FcfType(), fieldType)
+	isPtr := false
+	if !ok && fieldType.Kind() == reflect.Ptr && f.FcfType() != "nullValue" {
+		fieldType = fieldType.Elem()
+		fn, ok = d.converter(f.FcfType(), fieldType)
+		isPtr = true
+	}
+	if !ok {
+		return false, nil
+	}
+
+	result, err := fn(rawValue(f))
+	if err != nil {
+		return true, err
+	}
+	val := reflect.ValueOf(result)
+	if !val.IsValid() {
+		val = reflect.Zero(fieldType)
+	}
+	if !val.Type().ConvertibleTo(fieldType) {
+		return true, fmt.Errorf("Cannot convert %v returned by converter to %v", val.Type(), fieldType)
+	}
+	val = val.Convert(fieldType)
+	if isPtr {
+		ptr := reflect.New(fieldType)
+		ptr.Elem().Set(val)
+		val = ptr
+	}
+	f.Set(val)
+	return true, nil
+}
@@ -0,0 +1,114 @@
+package fcf
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type civilDate struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+func init() {
+	RegisterConverter("timestampValue", reflect.TypeOf(civilDate{}), func(raw interface{}) (interface{}, error) {
+		t, err := time.Parse(time.RFC3339Nano, raw.(string))
+		if err != nil {
+			return nil, err
+		}
+		return civilDate{t.Year(), t.Month(), t.Day()}, nil
+	})
+}
+
+func millis(raw interface{}) (interface{}, error) {
+	n, err := strconv.ParseInt(raw.(string), 10, 64)
+	return time.Duration(n) * time.Millisecond, err
+}
+
+func TestConverter(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"date":    map[string]interface{}{"timestampValue": "2020-03-04T05:06:07Z"},
+			"datePtr": map[string]interface{}{"timestampValue": "2021-01-02T00:00:00Z"},
+			"timeout": map[string]interface{}{"integerValue": "1500"},
+		},
+	}
+	type doc struct {
+		Date    civilDate     `fcf:"date"`
+		DatePtr *civilDate    `fcf:"datePtr"`
+		Timeout time.Duration `fcf:"timeout"`
+	}
+
+	var userVal doc
+	dec := NewDecoder(Converter("integerValue", reflect.TypeOf(time.Duration(0)), millis))
+	if err := dec.Decode(fcfVal, &userVal); err != nil {
+		t.Fatal(err)
+	}
+	expected := doc{
+		Date:    civilDate{2020, time.March, 4},
+		DatePtr: &civilDate{2021, time.January, 2},
+		Timeout: 1500 * time.Millisecond,
+	}
+	if !reflect.DeepEqual(userVal, expected) {
+		t.Errorf("expected %+v, got %+v", expected, userVal)
+	}
+
+	// without the decoder's converter, durations are nanoseconds
+	userVal = doc{}
+	if err := fcfVal.Decode(&userVal); err != nil {
+		t.Fatal(err)
+	}
+	if userVal.Timeout != 1500 {
+		t.Errorf("expected 1500ns, got %v", userVal.Timeout)
+	}
+}
+
+func TestConverterErrors(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"date": map[string]interface{}{"timestampValue": "yesterday"},
+		},
+	}
+	var userVal struct {
+		Date civilDate `fcf:"date"`
+	}
+	err := fcfVal.Decode(&userVal)
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.Path != "Date" {
+		t.Errorf("expected a *DecodeError for Date, got %v", err)
+	}
+
+	dec := NewDecoder(Converter("timestampValue", reflect.TypeOf(civilDate{}), func(raw interface{}) (interface{}, error) {
+		return "not a date", nil
+	}))
+	fcfVal.Fields["date"] = map[string]interface{}{"timestampValue": "2020-03-04T05:06:07Z"}
+	if err := dec.Decode(fcfVal, &userVal); err == nil {
+		t.Error("expected an error for a converter returning the wrong type")
+	}
+}
+
+func TestConverterNull(t *testing.T) {
+	var raws []interface{}
+	dec := NewDecoder(Converter("nullValue", reflect.TypeOf(""), func(raw interface{}) (interface{}, error) {
+		raws = append(raws, raw)
+		return "null", nil
+	}))
+	for _, null := range []interface{}{nil, "NULL_VALUE", 0} {
+		fcfVal := Value{
+			Fields: map[string]interface{}{
+				"Field": map[string]interface{}{"nullValue": null},
+			},
+		}
+		var userVal struct{ Field string }
+		if err := dec.Decode(fcfVal, &userVal); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(raws, []interface{}{nil, nil, nil}) {
+		t.Errorf("expected nil raw values, got %#v", raws)
+	}
+}
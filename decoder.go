@@ -0,0 +1,206 @@
+package fcf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Decoder decodes Firestore values into Go values.
+//
+// Struct fields are matched to firestore fields by the name in their
+// fcf or firestore tag (see DefaultTagNames), falling back to the field name.
+// Fields tagged fcf:"-" are ignored, and a map field tagged fcf:",remain"
+// collects the firestore fields no other field matches. The fields of
+// embedded structs are promoted as they are by encoding/json, unless
+// the embedded struct is tagged with a name or fcf:",nested".
+//
+// Integer and double values may also be decoded into big.Int, big.Float,
+// json.Number and string fields without losing precision.
+// In interface{} fields, geoPointValues decode to GeoPoint.
+// Values whose type implements Unmarshaler decode themselves, and string
+// and reference values are decoded into types implementing
+// encoding.TextUnmarshaler with UnmarshalText.
+// Converters take precedence over both.
+//
+// Every form of value the protobuf JSON mapping allows is accepted,
+// including empty maps and arrays with their fields or values omitted.
+//
+// A Decoder's behavior is set by the Options passed to NewDecoder, after
+// which it is safe for concurrent use by multiple goroutines.
+type Decoder struct {
+	options
+}
+
+// options holds the settings made by Options. Decoders and Encoders
+// share them so that the same Options can configure both.
+type options struct {
+	tags                  []string
+	disallowUnknownFields bool
+	converters            map[converterKey]ConverterFunc
+	numbers               NumberType
+	lossyNumbers          bool
+}
+
+// Option configures a Decoder or an Encoder.
+// Encoders ignore the Options that only affect decoding.
+type Option func(*options)
+
+// TagNames sets the struct tag keys the Decoder or Encoder consults,
+// in order of precedence. It defaults to DefaultTagNames.
+// Pass the same TagNames to a Decoder and an Encoder to round trip values.
+func TagNames(names ...string) Option {
+	return func(o *options) {
+		o.tags = append([]string(nil), names...)
+	}
+}
+
+// DisallowUnknownFields makes Decode fail when the document contains
+// fields that don't map to any field of the destination struct.
+// Fields decoded into maps and interfaces are never unknown.
+// The returned *DecodeError wraps an *UnknownFieldsError listing
+// every unknown field. Decoding still completes, so callers that only
+// want to report schema drift can log the error and use the result.
+func DisallowUnknownFields() Option {
+	return func(o *options) {
+		o.disallowUnknownFields = true
+	}
+}
+
+// NumberType selects the Go types that firestore numbers
+// decode to in interface{} fields
+type NumberType int
+
+const (
+	// IntNumbers decodes integerValues as int and doubleValues as float64.
+	// It is the default.
+	IntNumbers NumberType = iota
+	// Int64Numbers decodes integerValues as int64 and doubleValues as float64
+	Int64Numbers
+	// Float64Numbers decodes integerValues and doubleValues as float64,
+	// as encoding/json does
+	Float64Numbers
+	// JSONNumbers decodes integerValues and doubleValues as json.Number,
+	// so that integers beyond 2^53 survive being encoded as JSON.
+	// Non-finite doubles, which json.Number can't hold, decode as float64.
+	JSONNumbers
+)
+
+// DynamicNumbers sets the Go types that firestore numbers
+// decode to in interface{} fields. It defaults to IntNumbers.
+func DynamicNumbers(t NumberType) Option {
+	return func(o *options) {
+		o.numbers = t
+	}
+}
+
+// LossyNumbers makes Decode saturate numbers that are out of the range
+// of their field's type at its minimum or maximum instead of failing
+// with a *RangeError, and allows doubleValues to be decoded into
+// integer fields by truncating them towards zero
+func LossyNumbers() Option {
+	return func(o *options) {
+		o.lossyNumbers = true
+	}
+}
+
+// NewDecoder returns a Decoder configured by opts
+func NewDecoder(opts ...Option) *Decoder {
+	d := &Decoder{}
+	for _, opt := range opts {
+		opt(&d.options)
+	}
+	return d
+}
+
+var defaultDecoder = NewDecoder()
+
+// Decode reads the raw data from the fcf Value
+// and stores it in the user value pointed to by u.
+// See Decoder for the decoding rules.
+// Any error returned is a *DecodeError.
+func (d *Decoder) Decode(v Value, u interface{}) error {
+	usrVal := reflect.ValueOf(u)
+	if usrVal.Kind() != reflect.Ptr || usrVal.IsNil() {
+		return &DecodeError{
+			FcfType: "mapValue",
+			GoType:  reflect.TypeOf(u),
+			Err:     errors.New("Decode requires a non-nil pointer"),
+		}
+	}
+	if u, ok := u.(Unmarshaler); ok {
+		raw := map[string]interface{}{"fields": v.Fields}
+		if err := u.UnmarshalFirestore("mapValue", raw); err != nil {
+			return &DecodeError{FcfType: "mapValue", GoType: usrVal.Elem().Type(), Err: err}
+		}
+		return nil
+	}
+	state := &decodeState{Decoder: d}
+	if err := state.unmarshal(reflect.ValueOf(v.Fields), root{usrVal.Elem()}); err != nil {
+		return err
+	}
+	if len(state.unknownFields) > 0 {
+		sort.Strings(state.unknownFields)
+		return &DecodeError{
+			FcfType: "mapValue",
+			GoType:  usrVal.Elem().Type(),
+			Err:     &UnknownFieldsError{Paths: state.unknownFields},
+		}
+	}
+	return nil
+}
+
+func (o *options) tagNames() []string {
+	if o.tags == nil {
+		return DefaultTagNames
+	}
+	return o.tags
+}
+
+// dynamicType returns the Go type to decode fcfType into
+// when the field has the interface type t
+func (d *Decoder) dynamicType(fcfType string, fcfVal reflect.Value, t reflect.Type) reflect.Type {
+	switch fcfType {
+	case "integerValue":
+		switch d.numbers {
+		case Int64Numbers:
+			return reflect.TypeOf(int64(0))
+		case Float64Numbers:
+			return reflect.TypeOf(float64(0))
+		case JSONNumbers:
+			return jsonNumberType
+		}
+		return reflect.TypeOf(0)
+	case "doubleValue":
+		if d.numbers == JSONNumbers {
+			if f, err := convDouble(fcfVal); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+				return jsonNumberType
+			}
+		}
+		return reflect.TypeOf(float64(0))
+	}
+	return t
+}
+
+// decodeState holds the state of a single call to Decode
+type decodeState struct {
+	*Decoder
+	// unknownFields are the firestore paths of fields
+	// with no matching struct field
+	unknownFields []string
+}
+
+// UnknownFieldsError lists the document fields that had
+// no matching struct field when decoding with DisallowUnknownFields
+type UnknownFieldsError struct {
+	// Paths are the firestore paths of the unknown fields in sorted order,
+	// with array elements indexed, e.g. address.zip or lines[0].note
+	Paths []string
+}
+
+func (e *UnknownFieldsError) Error() string {
+	return fmt.Sprintf("unknown fields %s", strings.Join(e.Paths, ", "))
+}
@@ -0,0 +1,233 @@
+package fcf
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestDecoderTagNames(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"fcfName":   map[string]interface{}{"stringValue": "fcf"},
+			"jsonName":  map[string]interface{}{"stringValue": "json"},
+			"Untagged":  map[string]interface{}{"stringValue": "untagged"},
+			"storeName": map[string]interface{}{"stringValue": "firestore"},
+		},
+	}
+
+	type doc struct {
+		Field    string `fcf:"fcfName" json:"jsonName"`
+		Store    string `firestore:"storeName"`
+		Untagged string
+	}
+
+	var userVal doc
+	if err := NewDecoder(TagNames("json")).Decode(fcfVal, &userVal); err != nil {
+		t.Fatal(err)
+	}
+	expected := doc{Field: "json", Untagged: "untagged"}
+	if userVal != expected {
+		t.Errorf("expected %+v, got %+v", expected, userVal)
+	}
+
+	userVal = doc{}
+	if err := NewDecoder().Decode(fcfVal, &userVal); err != nil {
+		t.Fatal(err)
+	}
+	expected = doc{Field: "fcf", Store: "firestore", Untagged: "untagged"}
+	if userVal != expected {
+		t.Errorf("expected %+v, got %+v", expected, userVal)
+	}
+}
+
+func TestDecoderConcurrent(t *testing.T) {
+	dec := NewDecoder(TagNames("fcf"))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			var userVal struct{ Name string }
+			if err := dec.Decode(testDoc(name), &userVal); err != nil {
+				t.Error(err)
+				return
+			}
+			if userVal.Name != name {
+				t.Errorf("expected %q, got %q", name, userVal.Name)
+			}
+		}(string(rune('a' + i)))
+	}
+	wg.Wait()
+}
+
+func TestDisallowUnknownFields(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"Name":  map[string]interface{}{"stringValue": "alice"},
+			"Extra": map[string]interface{}{"stringValue": "extra"},
+			"Address": map[string]interface{}{"mapValue": map[string]interface{}{
+				"fields": map[string]interface{}{
+					"City":     map[string]interface{}{"stringValue": "Springfield"},
+					"zip code": map[string]interface{}{"stringValue": "12345"},
+				},
+			}},
+			"Lines": map[string]interface{}{"arrayValue": map[string]interface{}{
+				"values": []interface{}{
+					map[string]interface{}{"mapValue": map[string]interface{}{
+						"fields": map[string]interface{}{
+							"Text": map[string]interface{}{"stringValue": "1 Main St"},
+							"Note": map[string]interface{}{"stringValue": "note"},
+						},
+					}},
+				},
+			}},
+			"Meta": map[string]interface{}{"mapValue": map[string]interface{}{
+				"fields": map[string]interface{}{
+					"anything": map[string]interface{}{"stringValue": "goes"},
+				},
+			}},
+			"Location": map[string]interface{}{"geoPointValue": map[string]interface{}{
+				"latitude":  1.0,
+				"longitude": 2.0,
+			}},
+		},
+	}
+
+	type doc struct {
+		Name    string
+		Address struct {
+			City string
+		}
+		Lines []struct {
+			Text string
+		}
+		Meta     map[string]interface{}
+		Location struct {
+			Lat float64 `fcf:"latitude"`
+		}
+	}
+
+	var userVal doc
+	if err := fcfVal.Decode(&userVal); err != nil {
+		t.Fatalf("expected unknown fields to be ignored by default, got %v", err)
+	}
+
+	userVal = doc{}
+	err := NewDecoder(DisallowUnknownFields()).Decode(fcfVal, &userVal)
+	var unknownErr *UnknownFieldsError
+	if !errors.As(err, &unknownErr) {
+		t.Fatalf("expected an *UnknownFieldsError, got %v", err)
+	}
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Errorf("expected a *DecodeError, got %v", err)
+	}
+	expected := []string{"Address.`zip code`", "Extra", "Lines[0].Note"}
+	if !reflect.DeepEqual(unknownErr.Paths, expected) {
+		t.Errorf("expected %v, got %v", expected, unknownErr.Paths)
+	}
+	if userVal.Name != "alice" || userVal.Lines[0].Text != "1 Main St" {
+		t.Errorf("expected known fields to be decoded, got %+v", userVal)
+	}
+}
+
+func TestDynamicNumbers(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"int":    map[string]interface{}{"integerValue": "9007199254740993"},
+			"double": map[string]interface{}{"doubleValue": 1.5},
+		},
+	}
+	tests := []struct {
+		numbers  NumberType
+		expected map[string]interface{}
+	}{
+		{IntNumbers, map[string]interface{}{"int": 9007199254740993, "double": 1.5}},
+		{Int64Numbers, map[string]interface{}{"int": int64(9007199254740993), "double": 1.5}},
+		{Float64Numbers, map[string]interface{}{"int": float64(9007199254740992), "double": 1.5}},
+		{JSONNumbers, map[string]interface{}{"int": json.Number("9007199254740993"), "double": json.Number("1.5")}},
+	}
+	for _, test := range tests {
+		var userVal map[string]interface{}
+		if err := NewDecoder(DynamicNumbers(test.numbers)).Decode(fcfVal, &userVal); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(userVal, test.expected) {
+			t.Errorf("%d: expected %#v, got %#v", test.numbers, test.expected, userVal)
+		}
+	}
+}
+
+func TestJSONNumbersNonFinite(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"nan": map[string]interface{}{"doubleValue": "NaN"},
+			"inf": map[string]interface{}{"doubleValue": "-Infinity"},
+		},
+	}
+	var userVal map[string]interface{}
+	if err := NewDecoder(DynamicNumbers(JSONNumbers)).Decode(fcfVal, &userVal); err != nil {
+		t.Fatal(err)
+	}
+	if f, ok := userVal["nan"].(float64); !ok || !math.IsNaN(f) {
+		t.Errorf("expected a float64 NaN, got %#v", userVal["nan"])
+	}
+	if f, ok := userVal["inf"].(float64); !ok || !math.IsInf(f, -1) {
+		t.Errorf("expected a float64 -Inf, got %#v", userVal["inf"])
+	}
+
+	var numVal struct{ Nan json.Number }
+	fcfVal.Fields["Nan"] = fcfVal.Fields["nan"]
+	if err := fcfVal.Decode(&numVal); err == nil {
+		t.Errorf("expected error decoding NaN into a json.Number, got %q", numVal.Nan)
+	}
+}
+
+func TestLossyNumbers(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"Int8":    map[string]interface{}{"integerValue": "300"},
+			"Uint":    map[string]interface{}{"integerValue": "-5"},
+			"Float32": map[string]interface{}{"doubleValue": -1e39},
+			"Trunc":   map[string]interface{}{"doubleValue": -2.7},
+			"Big":     map[string]interface{}{"doubleValue": 1e30},
+			"Uint8":   map[string]interface{}{"doubleValue": 255.9},
+		},
+	}
+	type doc struct {
+		Int8    int8
+		Uint    uint
+		Float32 float32
+		Trunc   int
+		Big     int64
+		Uint8   uint8
+	}
+	var userVal doc
+	if err := fcfVal.Decode(&userVal); err == nil {
+		t.Error("expected an error without LossyNumbers")
+	}
+	userVal = doc{}
+	if err := NewDecoder(LossyNumbers()).Decode(fcfVal, &userVal); err != nil {
+		t.Fatal(err)
+	}
+	expected := doc{
+		Int8:    math.MaxInt8,
+		Uint:    0,
+		Float32: -math.MaxFloat32,
+		Trunc:   -2,
+		Big:     math.MaxInt64,
+		Uint8:   255,
+	}
+	if userVal != expected {
+		t.Errorf("expected %+v, got %+v", expected, userVal)
+	}
+
+	fcfVal.Fields = map[string]interface{}{"Trunc": map[string]interface{}{"doubleValue": math.NaN()}}
+	if err := NewDecoder(LossyNumbers()).Decode(fcfVal, &userVal); err == nil {
+		t.Error("expected an error decoding NaN into an int")
+	}
+}
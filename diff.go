@@ -0,0 +1,255 @@
+package fcf
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ChangeType says how a field differs between two documents
+type ChangeType int
+
+const (
+	// Added fields exist only in the new document
+	Added ChangeType = iota
+	// Removed fields exist only in the old document
+	Removed
+	// Modified fields exist in both documents with different values
+	Modified
+)
+
+func (t ChangeType) String() string {
+	switch t {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	}
+	return "unknown"
+}
+
+// Change is a single difference between two documents
+type Change struct {
+	Type ChangeType
+	// Path locates the changed value. Field names use Firestore field path
+	// syntax and array elements are indexed, e.g. address.lines[1]
+	Path string
+	// Old and New hold the values as Decode would produce them
+	// for an interface{} field. Old is nil for Added changes
+	// and New is nil for Removed changes.
+	Old interface{}
+	New interface{}
+
+	// elems holds the parsed Path: a string for each field name
+	// and an int for each array index
+	elems []interface{}
+}
+
+// Diff compares the fields of two documents and returns every changed value.
+// Maps and arrays are compared recursively, so a change deep inside
+// a map only reports that leaf. Array elements are compared by index;
+// when an array shrinks, its removed elements are reported last to first
+// so the changes can be applied in order. Vectors are compared as a whole.
+// Changes are ordered by path, with map keys sorted.
+func Diff(old, new Value) ([]Change, error) {
+	d := differ{dec: decodeState{Decoder: defaultDecoder}}
+	err := d.diffFields(reflect.ValueOf(old.Fields), reflect.ValueOf(new.Fields), nil)
+	if err != nil {
+		return nil, err
+	}
+	return d.changes, nil
+}
+
+type differ struct {
+	dec     decodeState
+	changes []Change
+}
+
+func appendElem(elems []interface{}, elem interface{}) []interface{} {
+	path := make([]interface{}, len(elems), len(elems)+1)
+	copy(path, elems)
+	return append(path, elem)
+}
+
+func formatPath(elems []interface{}) string {
+	var b strings.Builder
+	for _, elem := range elems {
+		switch elem := elem.(type) {
+		case int:
+			fmt.Fprintf(&b, "[%d]", elem)
+		case string:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(quoteFieldName(elem))
+		}
+	}
+	return b.String()
+}
+
+func (d *differ) add(changeType ChangeType, elems []interface{}, oldVal, newVal reflect.Value) error {
+	path := formatPath(elems)
+	change := Change{Type: changeType, Path: path, elems: elems}
+	var err error
+	if oldVal.IsValid() {
+		if change.Old, err = d.dec.decodeDynamic(oldVal.Interface(), path); err != nil {
+			return err
+		}
+	}
+	if newVal.IsValid() {
+		if change.New, err = d.dec.decodeDynamic(newVal.Interface(), path); err != nil {
+			return err
+		}
+	}
+	d.changes = append(d.changes, change)
+	return nil
+}
+
+func (d *differ) diffFields(oldFields, newFields reflect.Value, elems []interface{}) error {
+	keySet := map[string]bool{}
+	for _, fields := range []reflect.Value{oldFields, newFields} {
+		for _, key := range fields.MapKeys() {
+			keySet[key.String()] = true
+		}
+	}
+	keys := make([]string, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		path := appendElem(elems, key)
+		oldVal := oldFields.MapIndex(reflect.ValueOf(key))
+		newVal := newFields.MapIndex(reflect.ValueOf(key))
+		var err error
+		switch {
+		case !oldVal.IsValid():
+			err = d.add(Added, path, oldVal, newVal)
+		case !newVal.IsValid():
+			err = d.add(Removed, path, oldVal, newVal)
+		default:
+			err = d.diffValues(oldVal, newVal, path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *differ) diffArrays(oldValues, newValues reflect.Value, elems []interface{}) error {
+	n := oldValues.Len()
+	if newValues.Len() < n {
+		n = newValues.Len()
+	}
+	for i := 0; i < n; i++ {
+		if err := d.diffValues(oldValues.Index(i), newValues.Index(i), appendElem(elems, i)); err != nil {
+			return err
+		}
+	}
+	for i := oldValues.Len() - 1; i >= n; i-- {
+		if err := d.add(Removed, appendElem(elems, i), oldValues.Index(i), reflect.Value{}); err != nil {
+			return err
+		}
+	}
+	for i := n; i < newValues.Len(); i++ {
+		if err := d.add(Added, appendElem(elems, i), reflect.Value{}, newValues.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *differ) diffValues(oldWrapped, newWrapped reflect.Value, elems []interface{}) error {
+	path := formatPath(elems)
+	oldVal, oldType, err := unwrapFcfVal(oldWrapped)
+	if err != nil {
+		return &DecodeError{Path: path, Err: err}
+	}
+	newVal, newType, err := unwrapFcfVal(newWrapped)
+	if err != nil {
+		return &DecodeError{Path: path, Err: err}
+	}
+
+	if oldType == newType && (oldType == "mapValue" || oldType == "arrayValue") {
+		oldContainer, err := getContainer(oldVal, oldType)
+		if err != nil {
+			return &DecodeError{Path: path, FcfType: oldType, Err: err}
+		}
+		newContainer, err := getContainer(newVal, newType)
+		if err != nil {
+			return &DecodeError{Path: path, FcfType: newType, Err: err}
+		}
+		if oldType == "mapValue" {
+			// vectors are stored as maps but change as a whole
+			if isVector(oldContainer) || isVector(newContainer) {
+				if vectorsEqual(oldContainer, newContainer) {
+					return nil
+				}
+				return d.add(Modified, elems, oldWrapped, newWrapped)
+			}
+			return d.diffFields(oldContainer, newContainer, elems)
+		}
+		return d.diffArrays(oldContainer, newContainer, elems)
+	}
+
+	if oldType == newType && leafEqual(oldType, oldVal, newVal) {
+		return nil
+	}
+	return d.add(Modified, elems, oldWrapped, newWrapped)
+}
+
+// leafEqual compares two unwrapped firestore values of the same type
+func leafEqual(fcfType string, oldVal, newVal reflect.Value) bool {
+	if !oldVal.IsValid() || !newVal.IsValid() {
+		return oldVal.IsValid() == newVal.IsValid()
+	}
+	if fcfType == "timestampValue" && oldVal.Kind() == reflect.String && newVal.Kind() == reflect.String {
+		oldTime, oldErr := time.Parse(time.RFC3339Nano, oldVal.String())
+		newTime, newErr := time.Parse(time.RFC3339Nano, newVal.String())
+		if oldErr == nil && newErr == nil {
+			return oldTime.Equal(newTime)
+		}
+	}
+	if fcfType == "integerValue" {
+		oldInt, oldErr := convInteger(oldVal)
+		newInt, newErr := convInteger(newVal)
+		if oldErr == nil && newErr == nil {
+			return oldInt == newInt
+		}
+	}
+	if fcfType == "doubleValue" {
+		// compare the text so that NaN equals itself
+		oldDouble, oldErr := convDouble(oldVal)
+		newDouble, newErr := convDouble(newVal)
+		if oldErr == nil && newErr == nil {
+			return formatDouble(oldDouble) == formatDouble(newDouble)
+		}
+	}
+	return reflect.DeepEqual(oldVal.Interface(), newVal.Interface())
+}
+
+// vectorsEqual compares the elements of two vector maps,
+// reporting false if either isn't a valid vector
+func vectorsEqual(oldFields, newFields reflect.Value) bool {
+	if !isVector(oldFields) || !isVector(newFields) {
+		return false
+	}
+	oldElems, oldErr := vectorElems(oldFields)
+	newElems, newErr := vectorElems(newFields)
+	if oldErr != nil || newErr != nil || len(oldElems) != len(newElems) {
+		return false
+	}
+	for i := range oldElems {
+		// compare the text so that NaN equals itself
+		if formatDouble(oldElems[i]) != formatDouble(newElems[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,145 @@
+package fcf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	type address struct {
+		City  string
+		Lines []string
+	}
+	type doc struct {
+		Name    string
+		Age     int
+		Email   *string
+		Tags    []string
+		Address address
+		Meta    map[string]interface{}
+	}
+	email := "alice@example.com"
+	oldVal, err := NewValue(doc{
+		Name:    "alice",
+		Age:     30,
+		Email:   &email,
+		Tags:    []string{"a", "b", "c"},
+		Address: address{City: "Springfield", Lines: []string{"1 Main St"}},
+		Meta:    map[string]interface{}{"kept": true, "gone": "x", "a.b": 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newVal, err := NewValue(doc{
+		Name:    "alice",
+		Age:     31,
+		Tags:    []string{"a", "z"},
+		Address: address{City: "Shelbyville", Lines: []string{"1 Main St", "Apt 2"}},
+		Meta:    map[string]interface{}{"kept": true, "new": "y", "a.b": 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := Diff(oldVal, newVal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Change{
+		{Type: Modified, Path: "Address.City", Old: "Springfield", New: "Shelbyville"},
+		{Type: Added, Path: "Address.Lines[1]", New: "Apt 2"},
+		{Type: Modified, Path: "Age", Old: 30, New: 31},
+		{Type: Modified, Path: "Email", Old: email, New: nil},
+		{Type: Removed, Path: "Meta.gone", Old: "x"},
+		{Type: Added, Path: "Meta.new", New: "y"},
+		{Type: Modified, Path: "Tags[1]", Old: "b", New: "z"},
+		{Type: Removed, Path: "Tags[2]", Old: "c"},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d changes, got %d: %+v", len(expected), len(changes), changes)
+	}
+	for i, change := range changes {
+		e := expected[i]
+		if change.Type != e.Type || change.Path != e.Path ||
+			!reflect.DeepEqual(change.Old, e.Old) || !reflect.DeepEqual(change.New, e.New) {
+			t.Errorf("change %d: expected %v %s %v -> %v, got %v %s %v -> %v",
+				i, e.Type, e.Path, e.Old, e.New, change.Type, change.Path, change.Old, change.New)
+		}
+	}
+}
+
+func TestDiffSubtrees(t *testing.T) {
+	oldVal := Value{Fields: map[string]interface{}{
+		"Removed": map[string]interface{}{"mapValue": map[string]interface{}{
+			"fields": map[string]interface{}{"Inner": map[string]interface{}{"stringValue": "foo"}},
+		}},
+		"TypeChange": map[string]interface{}{"integerValue": "1"},
+		"Time":       map[string]interface{}{"timestampValue": "2019-02-03T01:07:05.5Z"},
+		"Int":        map[string]interface{}{"integerValue": "42"},
+		"NaN":        map[string]interface{}{"doubleValue": "NaN"},
+	}}
+	newVal := Value{Fields: map[string]interface{}{
+		"TypeChange": map[string]interface{}{"doubleValue": 1.0},
+		"Time":       map[string]interface{}{"timestampValue": "2019-02-03T01:07:05.500000Z"},
+		"Int":        map[string]interface{}{"integerValue": float64(42)},
+		"NaN":        map[string]interface{}{"doubleValue": "NaN"},
+	}}
+
+	changes, err := Diff(oldVal, newVal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %+v", changes)
+	}
+	removed := changes[0]
+	if removed.Type != Removed || removed.Path != "Removed" {
+		t.Errorf("expected Removed to be removed, got %v %s", removed.Type, removed.Path)
+	}
+	expectedOld := map[string]interface{}{"Inner": "foo"}
+	if !reflect.DeepEqual(removed.Old, expectedOld) {
+		t.Errorf("expected %v, got %v", expectedOld, removed.Old)
+	}
+	if changes[1].Type != Modified || changes[1].Path != "TypeChange" || changes[1].New != 1.0 {
+		t.Errorf("expected TypeChange to be modified, got %+v", changes[1])
+	}
+}
+
+func TestDiffVector(t *testing.T) {
+	oldVal := Value{Fields: map[string]interface{}{
+		"Embedding": vectorValue(map[string]interface{}{"doubleValue": 1.0}, map[string]interface{}{"doubleValue": 2.0}),
+		"NaN":       vectorValue(map[string]interface{}{"doubleValue": "NaN"}),
+		"Map":       vectorValue(),
+	}}
+	newVal := Value{Fields: map[string]interface{}{
+		"Embedding": vectorValue(map[string]interface{}{"doubleValue": 1.0}, map[string]interface{}{"integerValue": "3"}),
+		"NaN":       vectorValue(map[string]interface{}{"doubleValue": "NaN"}),
+		"Map": map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{
+			"value": map[string]interface{}{"arrayValue": map[string]interface{}{}},
+		}}},
+	}}
+
+	changes, err := Diff(oldVal, newVal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %+v", changes)
+	}
+	if changes[0].Type != Modified || changes[0].Path != "Embedding" || !reflect.DeepEqual(changes[0].New, Vector{1, 3}) {
+		t.Errorf("expected Embedding to be modified as a whole, got %+v", changes[0])
+	}
+	if changes[1].Type != Modified || changes[1].Path != "Map" {
+		t.Errorf("expected Map to be modified as a whole, got %+v", changes[1])
+	}
+}
+
+func TestDiffCreate(t *testing.T) {
+	changes, err := Diff(Value{}, testDoc("alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Type != Added || changes[0].Path != "Name" || changes[0].New != "alice" {
+		t.Errorf("expected Name to be added, got %+v", changes)
+	}
+}
@@ -0,0 +1,319 @@
+package fcf
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Encoder encodes Go values into the Firestore wire format.
+// It is the inverse of Decoder, so it honours the same struct tags
+// and type rules.
+//
+// Fields tagged with the omitempty option are left out when empty,
+// as are zero time.Time fields tagged with the serverTimestamp option.
+// Values implementing encoding.TextMarshaler are stored as stringValues.
+// big.Int, big.Float and json.Number values are stored as numbers,
+// and it is an error for an integer not to fit in an integerValue.
+//
+// Of the Options passed to NewEncoder only TagNames affects encoding,
+// so one set of Options can configure a Decoder and an Encoder alike.
+// An Encoder is safe for concurrent use by multiple goroutines.
+type Encoder struct {
+	options
+}
+
+// NewEncoder returns an Encoder configured by opts
+func NewEncoder(opts ...Option) *Encoder {
+	e := &Encoder{}
+	for _, opt := range opts {
+		opt(&e.options)
+	}
+	return e
+}
+
+var defaultEncoder = NewEncoder()
+
+// NewValue encodes u into a Value whose Fields can be written back to Firestore
+func NewValue(u interface{}) (Value, error) {
+	return defaultEncoder.NewValue(u)
+}
+
+// Encode converts the struct or map u into the Firestore wire format
+// that Value.Decode reads, as the default Encoder does
+func Encode(u interface{}) (map[string]interface{}, error) {
+	return defaultEncoder.Encode(u)
+}
+
+// NewValue encodes u into a Value whose Fields can be written back to Firestore
+func (e *Encoder) NewValue(u interface{}) (Value, error) {
+	fields, err := e.Encode(u)
+	if err != nil {
+		return Value{}, err
+	}
+	return Value{Fields: fields}, nil
+}
+
+// Encode converts the struct or map u, or a MapNode, into the Firestore
+// wire format. See Encoder for the encoding rules.
+func (e *Encoder) Encode(u interface{}) (map[string]interface{}, error) {
+	usrVal := reflect.ValueOf(u)
+	if !usrVal.IsValid() {
+		return nil, errors.New("Cannot encode nil")
+	}
+	for usrVal.Kind() == reflect.Ptr || usrVal.Kind() == reflect.Interface {
+		if usrVal.IsNil() {
+			return nil, fmt.Errorf("Cannot encode nil %v", usrVal.Type())
+		}
+		usrVal = usrVal.Elem()
+	}
+	if usrVal.Type() == nodeType {
+		n := usrVal.Interface().(Node)
+		if n.Kind() != MapNode {
+			return nil, fmt.Errorf("Can only encode map nodes into firestore fields, not %v nodes", n.Kind())
+		}
+		return encodeNodeFields(n.val.(map[string]Node)), nil
+	}
+	switch usrVal.Kind() {
+	case reflect.Struct:
+		return e.encodeStruct(usrVal, "")
+	case reflect.Map:
+		return e.encodeMap(usrVal, "")
+	}
+	return nil, fmt.Errorf("Can only encode Struct or Map types into firestore fields, not %v", usrVal.Kind())
+}
+
+func (e *Encoder) encodeStruct(usrVal reflect.Value, parentName string) (map[string]interface{}, error) {
+	structInfo := cachedFields(usrVal.Type(), e.tagNames())
+	fields := make(map[string]interface{}, len(structInfo.list))
+	for _, fieldMeta := range structInfo.list {
+		fieldVal, ok := existingFieldByIndex(usrVal, fieldMeta.index)
+		if !ok {
+			// field is promoted from a nil embedded struct pointer
+			continue
+		}
+		if fieldMeta.omitEmpty && isEmptyValue(fieldVal) {
+			continue
+		}
+		if fieldMeta.serverTimestamp && fieldVal.Type() == timeType && isEmptyValue(fieldVal) {
+			// the wire format has no server timestamp sentinel,
+			// so leave the field for the writer to transform
+			continue
+		}
+		name := fieldMeta.name
+		if parentName != "" {
+			name = parentName + "." + name
+		}
+		if _, ok := fields[fieldMeta.key]; ok {
+			// decoding fills every field sharing a key,
+			// but encoding can't pick which one to keep
+			return nil, fmt.Errorf("Error encoding field %s: another field is also stored as %q", name, fieldMeta.key)
+		}
+		var fcfVal interface{}
+		var err error
+		if fieldMeta.vector {
+			fcfVal, err = encodeVectorField(fieldVal, name)
+		} else {
+			fcfVal, err = e.encodeValue(fieldVal, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		fields[fieldMeta.key] = fcfVal
+	}
+	if structInfo.remain != nil {
+		if err := e.encodeRemain(usrVal, structInfo, parentName, fields); err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// encodeRemain adds the entries of the struct's remain map to fields.
+// Entries that collide with the struct's own fields are dropped.
+func (e *Encoder) encodeRemain(usrVal reflect.Value, structInfo structFields, parentName string, fields map[string]interface{}) error {
+	mapVal, ok := existingFieldByIndex(usrVal, structInfo.remain.index)
+	if !ok {
+		return nil
+	}
+	name := structInfo.remain.name
+	if parentName != "" {
+		name = parentName + "." + name
+	}
+	known := structInfo.keys()
+	for _, key := range mapVal.MapKeys() {
+		if known[key.String()] {
+			continue
+		}
+		fcfVal, err := e.encodeValue(mapVal.MapIndex(key), fmt.Sprintf("%s[%q]", name, key))
+		if err != nil {
+			return err
+		}
+		fields[key.String()] = fcfVal
+	}
+	return nil
+}
+
+func (e *Encoder) encodeMap(usrVal reflect.Value, parentName string) (map[string]interface{}, error) {
+	if usrVal.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("Error encoding field %s: map keys must be strings, not %v", parentName, usrVal.Type().Key())
+	}
+	fields := make(map[string]interface{}, usrVal.Len())
+	for _, key := range usrVal.MapKeys() {
+		fcfVal, err := e.encodeValue(usrVal.MapIndex(key), fmt.Sprintf("%s[%q]", parentName, key))
+		if err != nil {
+			return nil, err
+		}
+		fields[key.String()] = fcfVal
+	}
+	return fields, nil
+}
+
+func (e *Encoder) encodeSlice(usrVal reflect.Value, parentName string) ([]interface{}, error) {
+	values := make([]interface{}, 0, usrVal.Len())
+	for i := 0; i < usrVal.Len(); i++ {
+		fcfVal, err := e.encodeValue(usrVal.Index(i), fmt.Sprintf("%s[%d]", parentName, i))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, fcfVal)
+	}
+	return values, nil
+}
+
+func wrapFcfVal(fcfType string, val interface{}) map[string]interface{} {
+	return map[string]interface{}{fcfType: val}
+}
+
+func (e *Encoder) encodeValue(usrVal reflect.Value, name string) (interface{}, error) {
+	if !usrVal.IsValid() {
+		return wrapFcfVal("nullValue", nil), nil
+	}
+	switch usrVal.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		if usrVal.IsNil() {
+			return wrapFcfVal("nullValue", nil), nil
+		}
+	}
+
+	switch usrVal.Type() {
+	case timeType:
+		t := usrVal.Interface().(time.Time)
+		return wrapFcfVal("timestampValue", t.UTC().Format(time.RFC3339Nano)), nil
+	case geoPointType:
+		p := usrVal.Interface().(GeoPoint)
+		return wrapFcfVal("geoPointValue", map[string]interface{}{
+			"latitude":  p.Latitude,
+			"longitude": p.Longitude,
+		}), nil
+	case documentRefType:
+		ref := usrVal.Interface().(DocumentRef)
+		if ref == (DocumentRef{}) {
+			// a zero ref names no document, as when decoded from null
+			return wrapFcfVal("nullValue", nil), nil
+		}
+		return wrapFcfVal("referenceValue", ref.Name()), nil
+	case byteSliceType:
+		return wrapFcfVal("bytesValue", base64.StdEncoding.EncodeToString(usrVal.Bytes())), nil
+	case nodeType:
+		return encodeNode(usrVal.Interface().(Node)), nil
+	case vectorType:
+		return encodeVector(usrVal.Interface().(Vector)), nil
+	case bigIntType, bigFloatType, jsonNumberType:
+		return encodeNumber(usrVal, name)
+	}
+	if m, ok := textMarshaler(usrVal); ok {
+		text, err := m.MarshalText()
+		if err != nil {
+			return nil, fmt.Errorf("Error encoding field %s: %v", name, err)
+		}
+		return wrapFcfVal("stringValue", string(text)), nil
+	}
+
+	switch usrVal.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return e.encodeValue(usrVal.Elem(), name)
+	case reflect.Bool:
+		return wrapFcfVal("booleanValue", usrVal.Bool()), nil
+	case reflect.String:
+		return wrapFcfVal("stringValue", usrVal.String()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return wrapFcfVal("integerValue", strconv.FormatInt(usrVal.Int(), 10)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if usrVal.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("Error encoding field %s: %d overflows a firestore integerValue", name, usrVal.Uint())
+		}
+		return wrapFcfVal("integerValue", strconv.FormatUint(usrVal.Uint(), 10)), nil
+	case reflect.Float32, reflect.Float64:
+		return encodeDouble(usrVal.Float()), nil
+	case reflect.Slice, reflect.Array:
+		values, err := e.encodeSlice(usrVal, name)
+		if err != nil {
+			return nil, err
+		}
+		return wrapFcfVal("arrayValue", map[string]interface{}{"values": values}), nil
+	case reflect.Map:
+		fields, err := e.encodeMap(usrVal, name)
+		if err != nil {
+			return nil, err
+		}
+		return wrapFcfVal("mapValue", map[string]interface{}{"fields": fields}), nil
+	case reflect.Struct:
+		fields, err := e.encodeStruct(usrVal, name)
+		if err != nil {
+			return nil, err
+		}
+		return wrapFcfVal("mapValue", map[string]interface{}{"fields": fields}), nil
+	}
+	return nil, fmt.Errorf("Error encoding field %s: unsupported type %v", name, usrVal.Type())
+}
+
+// encodeDouble follows the protobuf JSON mapping,
+// which represents non-finite doubles as strings
+func encodeDouble(f float64) map[string]interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return wrapFcfVal("doubleValue", formatDouble(f))
+	}
+	return wrapFcfVal("doubleValue", f)
+}
+
+// encodeNumber encodes a big.Int, big.Float or json.Number,
+// which aren't limited to the range of a firestore integerValue
+func encodeNumber(usrVal reflect.Value, name string) (interface{}, error) {
+	switch usrVal.Type() {
+	case bigIntType:
+		i := addressable(usrVal).Addr().Interface().(*big.Int)
+		if !i.IsInt64() {
+			return nil, fmt.Errorf("Error encoding field %s: %v overflows a firestore integerValue", name, i)
+		}
+		return wrapFcfVal("integerValue", i.String()), nil
+	case bigFloatType:
+		f, _ := addressable(usrVal).Addr().Interface().(*big.Float).Float64()
+		return encodeDouble(f), nil
+	}
+	n := json.Number(usrVal.String())
+	if _, err := n.Int64(); err == nil {
+		return wrapFcfVal("integerValue", n.String()), nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil, fmt.Errorf("Error encoding field %s: invalid number %q", name, n)
+	}
+	return encodeDouble(f), nil
+}
+
+// addressable returns v, or an addressable copy of it
+func addressable(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v
+	}
+	ptr := reflect.New(v.Type())
+	ptr.Elem().Set(v)
+	return ptr.Elem()
+}
@@ -0,0 +1,228 @@
+package fcf
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type encodeInner struct {
+	Elem0 string
+	Elem1 int64 `fcf:"elem1"`
+}
+
+type encodeTestStruct struct {
+	String    string
+	Int       int
+	Int8      int8
+	Uint16    uint16
+	Float32   float32
+	Float64   float64
+	Bool      bool
+	Bytes     []byte
+	Time      time.Time
+	Geo       GeoPoint
+	Tagged    string `fcf:"otherName"`
+	Ptr       *string
+	NilPtr    *string
+	Slice     []string
+	EmptySl   []int
+	Map       map[string]int
+	Inner     encodeInner
+	InnerPtr  *encodeInner
+	Dynamic   interface{}
+	unexposed string
+}
+
+func TestEncodeWireFormat(t *testing.T) {
+	fields, err := Encode(struct {
+		String string
+		Int    int `fcf:"int"`
+		Ptr    *bool
+		Slice  []string
+		Map    map[string]bool
+	}{
+		String: "foo",
+		Int:    42,
+		Slice:  []string{"a"},
+		Map:    map[string]bool{"k": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"String": map[string]interface{}{"stringValue": "foo"},
+		"int":    map[string]interface{}{"integerValue": "42"},
+		"Ptr":    map[string]interface{}{"nullValue": nil},
+		"Slice": map[string]interface{}{"arrayValue": map[string]interface{}{
+			"values": []interface{}{map[string]interface{}{"stringValue": "a"}},
+		}},
+		"Map": map[string]interface{}{"mapValue": map[string]interface{}{
+			"fields": map[string]interface{}{"k": map[string]interface{}{"booleanValue": true}},
+		}},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected %v, got %v", expected, fields)
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	s := "bar"
+	testVal := encodeTestStruct{
+		String:   "foo",
+		Int:      -42,
+		Int8:     8,
+		Uint16:   16,
+		Float32:  3.5,
+		Float64:  6.25,
+		Bool:     true,
+		Bytes:    []byte("foobar"),
+		Time:     time.Date(2019, time.February, 3, 1, 7, 5, 565000000, time.UTC),
+		Geo:      GeoPoint{Latitude: 26.357896, Longitude: 127.783809},
+		Tagged:   "tagged",
+		Ptr:      &s,
+		Slice:    []string{"elem0", "elem1"},
+		EmptySl:  []int{},
+		Map:      map[string]int{"one": 1, "two": 2},
+		Inner:    encodeInner{Elem0: "inner", Elem1: 64},
+		InnerPtr: &encodeInner{Elem0: "ptr"},
+		Dynamic:  map[string]interface{}{"s": "str", "i": 3, "b": true},
+	}
+
+	fcfVal, err := NewValue(&testVal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fcfVal.Fields["unexposed"]; ok {
+		t.Errorf("unexported field should not be encoded")
+	}
+
+	userVal := encodeTestStruct{}
+	if err := fcfVal.Decode(&userVal); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(testVal, userVal) {
+		t.Errorf("round trip mismatch:\nexpected %+v\ngot      %+v", testVal, userVal)
+	}
+}
+
+func TestEncoderTagNames(t *testing.T) {
+	type inner struct {
+		Field string `fcf:"fcfInner" json:"jsonInner"`
+	}
+	type doc struct {
+		Field string `fcf:"fcfName" json:"jsonName"`
+		Inner inner  `json:"jsonNested"`
+	}
+	testVal := doc{Field: "foo", Inner: inner{Field: "bar"}}
+
+	enc := NewEncoder(TagNames("json"))
+	fields, err := enc.Encode(testVal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fields["jsonName"]; !ok {
+		t.Errorf("expected the json tag name, got %v", fields)
+	}
+	nested, _ := fields["jsonNested"].(map[string]interface{})["mapValue"].(map[string]interface{})["fields"].(map[string]interface{})
+	if _, ok := nested["jsonInner"]; !ok {
+		t.Errorf("expected the nested json tag name, got %v", fields["jsonNested"])
+	}
+
+	var userVal doc
+	if err := NewDecoder(TagNames("json")).Decode(Value{Fields: fields}, &userVal); err != nil {
+		t.Fatal(err)
+	}
+	if userVal != testVal {
+		t.Errorf("round trip mismatch:\nexpected %+v\ngot      %+v", testVal, userVal)
+	}
+
+	fields, err = Encode(testVal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fields["fcfName"]; !ok {
+		t.Errorf("expected the default tag name, got %v", fields)
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	if _, err := Encode(nil); err == nil {
+		t.Errorf("expected error encoding nil")
+	}
+	if _, err := Encode("foo"); err == nil {
+		t.Errorf("expected error encoding a string as a document")
+	}
+	if _, err := Encode(Node{IntegerNode, int64(1)}); err == nil {
+		t.Errorf("expected error encoding an integer node as a document")
+	}
+	if _, err := Encode(&Node{}); err == nil {
+		t.Errorf("expected error encoding a null node as a document")
+	}
+	if _, err := Encode(map[int]string{1: "foo"}); err == nil {
+		t.Errorf("expected error encoding a map with non-string keys")
+	}
+	if _, err := Encode(struct{ C chan int }{}); err == nil {
+		t.Errorf("expected error encoding a chan field")
+	}
+	if _, err := Encode(struct{ U uint64 }{U: 1 << 63}); err == nil {
+		t.Errorf("expected error encoding an out of range uint64")
+	}
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	if _, err := Encode(struct{ I *big.Int }{I: huge}); err == nil {
+		t.Errorf("expected error encoding an out of range big.Int")
+	}
+	if _, err := Encode(struct{ N json.Number }{N: "one"}); err == nil {
+		t.Errorf("expected error encoding an invalid json.Number")
+	}
+}
+
+func TestEncodeNonFiniteDoubles(t *testing.T) {
+	fields, err := Encode(map[string]interface{}{
+		"nan":    math.NaN(),
+		"inf":    math.Inf(1),
+		"negInf": float32(math.Inf(-1)),
+		"finite": 1.5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"nan":    map[string]interface{}{"doubleValue": "NaN"},
+		"inf":    map[string]interface{}{"doubleValue": "Infinity"},
+		"negInf": map[string]interface{}{"doubleValue": "-Infinity"},
+		"finite": map[string]interface{}{"doubleValue": 1.5},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected %v, got %v", expected, fields)
+	}
+}
+
+func TestEncodeBigNumbers(t *testing.T) {
+	fields, err := Encode(struct {
+		BigInt    *big.Int
+		BigFloat  big.Float
+		Number    json.Number
+		NumberDbl json.Number
+	}{
+		BigInt:    big.NewInt(math.MaxInt64),
+		BigFloat:  *big.NewFloat(2.5),
+		Number:    "9007199254740993",
+		NumberDbl: "0.1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"BigInt":    map[string]interface{}{"integerValue": "9223372036854775807"},
+		"BigFloat":  map[string]interface{}{"doubleValue": 2.5},
+		"Number":    map[string]interface{}{"integerValue": "9007199254740993"},
+		"NumberDbl": map[string]interface{}{"doubleValue": 0.1},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected %v, got %v", expected, fields)
+	}
+}
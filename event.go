@@ -0,0 +1,71 @@
+package fcf
+
+import "errors"
+
+// ErrNoDocument is returned when decoding the side of an Event
+// that has no document, e.g. the OldValue of a create event
+var ErrNoDocument = errors.New("document does not exist")
+
+// EventKind classifies an Event as a create, update or delete
+type EventKind int
+
+const (
+	// Unknown is the kind of an Event where neither value exists
+	Unknown EventKind = iota
+	// Create is the kind of an Event with only a new value
+	Create
+	// Update is the kind of an Event with both an old and a new value
+	Update
+	// Delete is the kind of an Event with only an old value
+	Delete
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case Create:
+		return "create"
+	case Update:
+		return "update"
+	case Delete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// Exists reports whether v holds a document.
+// Firestore sends an empty value for the missing side of creates and deletes.
+func (v Value) Exists() bool {
+	return v.Name != "" || len(v.Fields) != 0
+}
+
+// Kind classifies the event based on which of its values exist
+func (e Event) Kind() EventKind {
+	oldExists, newExists := e.OldValue.Exists(), e.Value.Exists()
+	switch {
+	case oldExists && newExists:
+		return Update
+	case newExists:
+		return Create
+	case oldExists:
+		return Delete
+	}
+	return Unknown
+}
+
+// DecodeOld decodes the document as it was before the event into u.
+// It returns ErrNoDocument if there was no document before the event.
+func (e Event) DecodeOld(u interface{}) error {
+	if !e.OldValue.Exists() {
+		return ErrNoDocument
+	}
+	return e.OldValue.Decode(u)
+}
+
+// DecodeNew decodes the document as it is after the event into u.
+// It returns ErrNoDocument if there is no document after the event.
+func (e Event) DecodeNew(u interface{}) error {
+	if !e.Value.Exists() {
+		return ErrNoDocument
+	}
+	return e.Value.Decode(u)
+}
@@ -0,0 +1,80 @@
+package fcf
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const testDocName = "projects/project-name/databases/(default)/documents/users/alice"
+
+func testDoc(name string) Value {
+	return Value{
+		Name: testDocName,
+		Fields: map[string]interface{}{
+			"Name": map[string]interface{}{"stringValue": name},
+		},
+	}
+}
+
+func TestEventKind(t *testing.T) {
+	tests := []struct {
+		event    Event
+		expected EventKind
+	}{
+		{Event{Value: testDoc("alice")}, Create},
+		{Event{OldValue: testDoc("alice"), Value: testDoc("bob")}, Update},
+		{Event{OldValue: testDoc("alice")}, Delete},
+		{Event{}, Unknown},
+	}
+	for _, test := range tests {
+		if kind := test.event.Kind(); kind != test.expected {
+			t.Errorf("expected %v, got %v", test.expected, kind)
+		}
+	}
+}
+
+func TestEventKindFromJSON(t *testing.T) {
+	payload := `{
+		"oldValue": {},
+		"value": {
+			"createTime": "2019-02-03T01:07:05.565Z",
+			"fields": {"Name": {"stringValue": "alice"}},
+			"name": "` + testDocName + `",
+			"updateTime": "2019-02-03T01:07:05.565Z"
+		},
+		"updateMask": {}
+	}`
+	var e Event
+	if err := json.Unmarshal([]byte(payload), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Kind() != Create {
+		t.Errorf("expected %v, got %v", Create, e.Kind())
+	}
+}
+
+func TestEventDecode(t *testing.T) {
+	e := Event{Value: testDoc("alice")}
+
+	var userVal struct{ Name string }
+	if err := e.DecodeNew(&userVal); err != nil {
+		t.Fatal(err)
+	}
+	if userVal.Name != "alice" {
+		t.Errorf("expected %q, got %q", "alice", userVal.Name)
+	}
+	if err := e.DecodeOld(&userVal); err != ErrNoDocument {
+		t.Errorf("expected %v, got %v", ErrNoDocument, err)
+	}
+
+	e = Event{OldValue: testDoc("bob")}
+	if err := e.DecodeOld(&userVal); err != nil {
+		t.Fatal(err)
+	}
+	if userVal.Name != "bob" {
+		t.Errorf("expected %q, got %q", "bob", userVal.Name)
+	}
+	if err := e.DecodeNew(&userVal); err != ErrNoDocument {
+		t.Errorf("expected %v, got %v", ErrNoDocument, err)
+	}
+}
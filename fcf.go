@@ -2,7 +2,11 @@ package fcf // import "github.com/zevdg/fcf"
 
 import (
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"math/big"
 	"reflect"
 	"strconv"
 	"strings"
@@ -33,18 +37,84 @@ type Value struct {
 // You can use your own type as long as it has has
 // the correct latitude/longitude struct tags
 type GeoPoint struct {
-	Latitude  float64 `fcf:"latitude"`
-	Longitude float64 `fcf:"longitude"`
+	Latitude  float64 `fcf:"latitude" json:"latitude"`
+	Longitude float64 `fcf:"longitude" json:"longitude"`
 }
 
-// Decode reads the raw data from the fcf Value
-// and stores it in the user value pointed to by u
+// Decode reads the raw data from the fcf Value and stores it in the
+// user value pointed to by u, as the default Decoder does
 func (v Value) Decode(u interface{}) error {
-	return unmarshal(reflect.Indirect(reflect.ValueOf(v)).FieldByName("Fields"), root{reflect.Indirect(reflect.ValueOf(u))})
+	return defaultDecoder.Decode(v, u)
+}
+
+// DecodeError describes a Firestore value that could not be decoded
+// into the corresponding field of the user's value
+type DecodeError struct {
+	// Path is the Go path of the field being decoded (e.g. S.Inner["key"][0]).
+	// It is empty for the root value.
+	Path string
+	// FcfType is the Firestore union type of the value (e.g. integerValue).
+	// It is empty for the raw members of a geoPointValue.
+	FcfType string
+	// GoType is the type of the field being decoded into
+	GoType reflect.Type
+	// Err is the underlying cause
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "<root>"
+	}
+	fcfType := e.FcfType
+	if fcfType == "" {
+		fcfType = "value"
+	}
+	return fmt.Sprintf("Error decoding firestore %s into %v field %s: %v", fcfType, e.GoType, path, e.Err)
+}
+
+// Unwrap returns the underlying cause of the error
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// RangeError reports a firestore number that doesn't fit
+// in the Go type it's being decoded into
+type RangeError struct {
+	// Value is the number as text
+	Value string
+	// GoType is the type the number was being decoded into
+	GoType reflect.Type
+	// Err is the underlying *strconv.NumError, if any
+	Err error
+}
+
+func (e *RangeError) Error() string {
+	return fmt.Sprintf("value %s out of range for %v", e.Value, e.GoType)
+}
+
+// Unwrap returns the underlying cause of the error
+func (e *RangeError) Unwrap() error {
+	return e.Err
+}
+
+func newDecodeError(f field, err error) *DecodeError {
+	return &DecodeError{
+		Path:    f.Name(),
+		FcfType: f.FcfType(),
+		GoType:  f.Type(),
+		Err:     err,
+	}
 }
 
 var byteSlice []byte
 var byteSliceType = reflect.TypeOf(byteSlice)
+var timeType = reflect.TypeOf(time.Time{})
+var geoPointType = reflect.TypeOf(GeoPoint{})
+var bigIntType = reflect.TypeOf(big.Int{})
+var bigFloatType = reflect.TypeOf(big.Float{})
+var jsonNumberType = reflect.TypeOf(json.Number(""))
 
 func assertTypeMatch(userType reflect.Type, fcfType string) error {
 	userKind := userType.Kind()
@@ -55,13 +125,18 @@ func assertTypeMatch(userType reflect.Type, fcfType string) error {
 		return fmt.Errorf("type mismatch: Cannot unmarshal firestore values into non-empty interface: %v", userType)
 	}
 	if userKind == reflect.Ptr {
-		userKind = userType.Elem().Kind()
+		userType = userType.Elem()
+		userKind = userType.Kind()
 	}
+	isNumber := fcfType == "integerValue" || fcfType == "doubleValue"
 
 	if (fcfType == "integerValue" && reflect.Int <= userKind && userKind <= reflect.Float64) ||
 		(fcfType == "doubleValue" && (userKind == reflect.Float32 || userKind == reflect.Float64)) ||
+		(isNumber && (userKind == reflect.String || userType == bigFloatType)) ||
+		(fcfType == "integerValue" && userType == bigIntType) ||
 		(fcfType == "timestampValue" && userType.PkgPath() == "time" && userType.Name() == "Time") ||
 		((fcfType == "stringValue" || fcfType == "referenceValue") && userKind == reflect.String) ||
+		(fcfType == "referenceValue" && userType == documentRefType) ||
 		(fcfType == "mapValue" && (userKind == reflect.Struct || userKind == reflect.Map)) ||
 		(fcfType == "arrayValue" && userKind == reflect.Slice) ||
 		(fcfType == "bytesValue" && userType == byteSliceType) ||
@@ -75,6 +150,7 @@ func assertTypeMatch(userType reflect.Type, fcfType string) error {
 
 type structField struct {
 	name    string
+	fcfPath string
 	fcfType string
 	fcf     reflect.Value
 	val     reflect.Value
@@ -88,6 +164,10 @@ func (f structField) Name() string {
 	return f.name
 }
 
+func (f structField) FcfPath() string {
+	return f.fcfPath
+}
+
 func (f structField) FcfType() string {
 	return f.fcfType
 }
@@ -110,6 +190,7 @@ func (f structField) Set(newVal reflect.Value) {
 
 type mapField struct {
 	name    string
+	fcfPath string
 	key     reflect.Value
 	fcfType string
 	fcf     reflect.Value
@@ -123,6 +204,10 @@ func (f mapField) String() string {
 func (f mapField) Name() string {
 	return f.name
 }
+func (f mapField) FcfPath() string {
+	return f.fcfPath
+}
+
 func (f mapField) FcfType() string {
 	return f.fcfType
 }
@@ -150,6 +235,18 @@ func initField(f field) reflect.Value {
 	return reflect.Zero(f.Type())
 }
 
+func isIntKind(k reflect.Kind) bool {
+	return reflect.Int <= k && k <= reflect.Uintptr
+}
+
+// elemKind returns the kind of t, or of its element if it's a pointer
+func elemKind(t reflect.Type) reflect.Kind {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem().Kind()
+	}
+	return t.Kind()
+}
+
 func isReferenceType(k reflect.Kind) bool {
 	return k == reflect.Ptr || k == reflect.Slice || k == reflect.Map || k == reflect.Interface
 }
@@ -160,6 +257,7 @@ func (f mapField) Set(newVal reflect.Value) {
 
 type sliceField struct {
 	name    string
+	fcfPath string
 	i       int
 	fcfType string
 	fcf     reflect.Value
@@ -173,6 +271,10 @@ func (f sliceField) String() string {
 func (f sliceField) Name() string {
 	return f.name
 }
+func (f sliceField) FcfPath() string {
+	return f.fcfPath
+}
+
 func (f sliceField) FcfType() string {
 	return f.fcfType
 }
@@ -201,6 +303,10 @@ func (r root) Name() string {
 	return ""
 }
 
+func (r root) FcfPath() string {
+	return ""
+}
+
 func (r root) getOrInit() reflect.Value {
 	return r.val
 }
@@ -218,22 +324,35 @@ type field interface {
 }
 
 type fieldBag interface {
+	// Name is the Go path of the value
 	Name() string
+	// FcfPath is the firestore path of the value
+	FcfPath() string
 	getOrInit() reflect.Value
 	Set(reflect.Value)
 }
 
-func unwrapFcfVal(wrappedVal reflect.Value) (unwrappedVal reflect.Value, fcfType string) {
+func unwrapFcfVal(wrappedVal reflect.Value) (unwrappedVal reflect.Value, fcfType string, err error) {
 	wrappedVal = wrappedVal.Elem() // sheds interface{} outer layer
 	if wrappedVal.Kind() != reflect.Map {
 		// raw value special case (e.g. GeoPoint fields)
-		return wrappedVal, ""
+		return wrappedVal, "", nil
+	}
+	if wrappedVal.Len() == 0 {
+		// a value with no type set is null
+		return reflect.Value{}, "nullValue", nil
+	}
+	if wrappedVal.Len() != 1 {
+		return reflect.Value{}, "", fmt.Errorf("malformed firestore value: expected exactly one type key, got %v", wrappedVal.MapKeys())
 	}
 	fcfUnionType := wrappedVal.MapKeys()[0]
-	return wrappedVal.MapIndex(fcfUnionType).Elem(), fcfUnionType.String()
+	if fcfUnionType.Kind() != reflect.String {
+		return reflect.Value{}, "", fmt.Errorf("malformed firestore value: type key %v is not a string", fcfUnionType)
+	}
+	return wrappedVal.MapIndex(fcfUnionType).Elem(), fcfUnionType.String(), nil
 }
 
-func getSliceFields(fcfVal reflect.Value, usrVal reflect.Value, parentName string) (reflect.Value, []field, error) {
+func getSliceFields(fcfVal reflect.Value, usrVal reflect.Value, parent fieldBag) (reflect.Value, []field, error) {
 	if !(usrVal.Kind() == reflect.Slice ||
 		(usrVal.Kind() == reflect.Interface && usrVal.Type().NumMethod() == 0)) {
 		typeStr := usrVal.Kind().String()
@@ -250,15 +369,24 @@ func getSliceFields(fcfVal reflect.Value, usrVal reflect.Value, parentName strin
 	} else {
 		sliceType = usrVal.Type()
 	}
-	if usrVal.IsNil() {
-		usrVal = reflect.MakeSlice(sliceType, fcfVal.Len(), fcfVal.Len())
+	// reuse the existing slice if it has room, as encoding/json does,
+	// but always give it exactly as many elements as the array
+	n := fcfVal.Len()
+	if usrVal.Kind() == reflect.Interface || usrVal.IsNil() || usrVal.Cap() < n {
+		usrVal = reflect.MakeSlice(sliceType, n, n)
+	} else {
+		usrVal = usrVal.Slice(0, n)
 	}
 	fields := make([]field, 0, fcfVal.Len())
 	for i := 0; i < fcfVal.Len(); i++ {
-
-		fcfFieldVal, fcfType := unwrapFcfVal(fcfVal.Index(i))
+		name := fmt.Sprintf("%s[%d]", parent.Name(), i)
+		fcfFieldVal, fcfType, err := unwrapFcfVal(fcfVal.Index(i))
+		if err != nil {
+			return reflect.Value{}, nil, &DecodeError{Path: name, GoType: sliceType.Elem(), Err: err}
+		}
 		fields = append(fields, sliceField{
-			name:    fmt.Sprintf("%s[%d]", parentName, i),
+			name:    name,
+			fcfPath: fmt.Sprintf("%s[%d]", parent.FcfPath(), i),
 			i:       i,
 			fcfType: fcfType,
 			fcf:     fcfFieldVal,
@@ -268,35 +396,48 @@ func getSliceFields(fcfVal reflect.Value, usrVal reflect.Value, parentName strin
 	return usrVal, fields, nil
 }
 
-func getStructFields(fcfVal reflect.Value, usrVal reflect.Value, parentName string) (reflect.Value, []field, error) {
+func (d *decodeState) getStructFields(fcfVal reflect.Value, usrVal reflect.Value, parent fieldBag) (reflect.Value, []field, error) {
 	usrValElem := reflect.Indirect(usrVal)
-	fields := make([]field, 0, usrValElem.Type().NumField())
-	for i := 0; i < usrValElem.Type().NumField(); i++ {
-		fieldMeta := usrValElem.Type().Field(i)
-		key := fieldMeta.Name
-		if tag := fieldMeta.Tag.Get("fcf"); tag != "" {
-			key = tag
-		}
-		wrappedVal := fcfVal.MapIndex(reflect.ValueOf(key))
+	structInfo := cachedFields(usrValElem.Type(), d.tagNames())
+	parentName := parent.Name()
+	fields := make([]field, 0, len(structInfo.list))
+	if structInfo.remain != nil && !isGeoPoint(parent) {
+		remainFields, err := getRemainFields(fcfVal, usrValElem, structInfo, parent)
+		if err != nil {
+			return reflect.Value{}, nil, err
+		}
+		fields = append(fields, remainFields...)
+	} else if d.disallowUnknownFields && !isGeoPoint(parent) {
+		d.checkUnknownFields(fcfVal, structInfo, parent)
+	}
+	for _, fieldMeta := range structInfo.list {
+		wrappedVal := fcfVal.MapIndex(reflect.ValueOf(fieldMeta.key))
 		if !wrappedVal.IsValid() {
 			// field on user's struct doesn't exist in firestore data
 			// skip it
 			continue
 		}
-		fcfFieldVal, fcfType := unwrapFcfVal(wrappedVal)
-		fieldVal := usrValElem.Field(i)
+		name := fieldMeta.name
+		if parentName != "" {
+			name = parentName + "." + name
+		}
+		fcfFieldVal, fcfType, err := unwrapFcfVal(wrappedVal)
+		if err != nil {
+			return reflect.Value{}, nil, &DecodeError{Path: name, GoType: fieldMeta.typ, Err: err}
+		}
+		fieldVal, err := fieldByIndex(usrValElem, fieldMeta.index)
+		if err != nil {
+			return reflect.Value{}, nil, &DecodeError{Path: name, FcfType: fcfType, GoType: fieldMeta.typ, Err: err}
+		}
 		if fieldVal.Kind() == reflect.Ptr && fcfType != "nullValue" {
 			if fieldVal.IsNil() {
 				fieldVal.Set(reflect.New(fieldVal.Type().Elem()))
 			}
 			fieldVal = fieldVal.Elem()
 		}
-		name := fieldMeta.Name
-		if parentName != "" {
-			name = parentName + "." + name
-		}
 		fields = append(fields, structField{
 			name:    name,
+			fcfPath: joinFcfPath(parent.FcfPath(), fieldMeta.key),
 			fcfType: fcfType,
 			fcf:     fcfFieldVal,
 			val:     fieldVal,
@@ -305,7 +446,45 @@ func getStructFields(fcfVal reflect.Value, usrVal reflect.Value, parentName stri
 	return usrVal, fields, nil
 }
 
-func getMapFields(fcfVal reflect.Value, usrVal reflect.Value, parentName string) (reflect.Value, []field, error) {
+// getRemainFields returns the firestore fields of fcfVal that don't map
+// to any of the user's struct fields, to be decoded into its remain map
+func getRemainFields(fcfVal reflect.Value, usrValElem reflect.Value, structInfo structFields, parent fieldBag) ([]field, error) {
+	remain := structInfo.remain
+	name := remain.name
+	if parent.Name() != "" {
+		name = parent.Name() + "." + name
+	}
+	mapVal, err := fieldByIndex(usrValElem, remain.index)
+	if err != nil {
+		return nil, &DecodeError{Path: name, FcfType: "mapValue", GoType: remain.typ, Err: err}
+	}
+	known := structInfo.keys()
+	var fields []field
+	for _, key := range fcfVal.MapKeys() {
+		if known[key.String()] {
+			continue
+		}
+		if mapVal.IsNil() {
+			mapVal.Set(reflect.MakeMap(mapVal.Type()))
+		}
+		fieldName := fmt.Sprintf("%s[%q]", name, key)
+		fcfFieldVal, fcfType, err := unwrapFcfVal(fcfVal.MapIndex(key))
+		if err != nil {
+			return nil, &DecodeError{Path: fieldName, GoType: mapVal.Type().Elem(), Err: err}
+		}
+		fields = append(fields, mapField{
+			name:    fieldName,
+			fcfPath: joinFcfPath(parent.FcfPath(), key.String()),
+			key:     key.Convert(mapVal.Type().Key()),
+			fcfType: fcfType,
+			fcf:     fcfFieldVal,
+			parent:  mapVal,
+		})
+	}
+	return fields, nil
+}
+
+func (d *decodeState) getMapFields(fcfVal reflect.Value, usrVal reflect.Value, parent fieldBag) (reflect.Value, []field, error) {
 	kind := usrVal.Kind()
 	if kind == reflect.Ptr {
 		kind = usrVal.Elem().Kind()
@@ -323,7 +502,7 @@ func getMapFields(fcfVal reflect.Value, usrVal reflect.Value, parentName string)
 
 	if kind == reflect.Struct {
 		// get fields from usrVal
-		return getStructFields(fcfVal, usrVal, parentName)
+		return d.getStructFields(fcfVal, usrVal, parent)
 	}
 
 	// usrVal is Map, Slice, or empty interface
@@ -340,9 +519,14 @@ func getMapFields(fcfVal reflect.Value, usrVal reflect.Value, parentName string)
 	}
 	fields := make([]field, 0, len(fcfVal.MapKeys()))
 	for _, key := range fcfVal.MapKeys() {
-		fcfFieldVal, fcfType := unwrapFcfVal(fcfVal.MapIndex(key))
+		name := fmt.Sprintf("%s[%q]", parent.Name(), key)
+		fcfFieldVal, fcfType, err := unwrapFcfVal(fcfVal.MapIndex(key))
+		if err != nil {
+			return reflect.Value{}, nil, &DecodeError{Path: name, GoType: mapType.Elem(), Err: err}
+		}
 		fields = append(fields, mapField{
-			name:    fmt.Sprintf("%s[%q]", parentName, key),
+			name:    name,
+			fcfPath: joinFcfPath(parent.FcfPath(), key.String()),
 			key:     key,
 			fcfType: fcfType,
 			fcf:     fcfFieldVal,
@@ -352,126 +536,591 @@ func getMapFields(fcfVal reflect.Value, usrVal reflect.Value, parentName string)
 	return usrVal, fields, nil
 }
 
-func getFields(fcfVal reflect.Value, usrVal fieldBag) (reflect.Value, []field, error) {
+func (d *decodeState) getFields(fcfVal reflect.Value, usrVal fieldBag) (reflect.Value, []field, error) {
 	uVal, parentName := usrVal.getOrInit(), usrVal.Name()
+	var newVal reflect.Value
+	var fields []field
+	var err error
 	if fcfVal.Kind() == reflect.Slice {
-		return getSliceFields(fcfVal, uVal, parentName)
+		newVal, fields, err = getSliceFields(fcfVal, uVal, usrVal)
+	} else {
+		newVal, fields, err = d.getMapFields(fcfVal, uVal, usrVal)
+	}
+	if err != nil {
+		if _, ok := err.(*DecodeError); !ok {
+			err = &DecodeError{Path: parentName, GoType: uVal.Type(), Err: err}
+		}
+	}
+	return newVal, fields, err
+}
+
+// joinFcfPath appends a firestore field name to a firestore path
+func joinFcfPath(parentPath string, key string) string {
+	if parentPath == "" {
+		return quoteFieldName(key)
+	}
+	return parentPath + "." + quoteFieldName(key)
+}
+
+func isGeoPoint(f fieldBag) bool {
+	field, ok := f.(field)
+	return ok && field.FcfType() == "geoPointValue"
+}
+
+// checkUnknownFields records the firestore fields of fcfVal
+// that don't map to any of the user's struct fields
+func (d *decodeState) checkUnknownFields(fcfVal reflect.Value, structInfo structFields, parent fieldBag) {
+	known := structInfo.keys()
+	for _, key := range fcfVal.MapKeys() {
+		if !known[key.String()] {
+			d.unknownFields = append(d.unknownFields, joinFcfPath(parent.FcfPath(), key.String()))
+		}
+	}
+}
+
+// getContainer returns the fields of a mapValue or the values of an arrayValue
+func getContainer(fcfVal reflect.Value, fcfType string) (reflect.Value, error) {
+	key, kind := "fields", reflect.Map
+	if fcfType == "arrayValue" {
+		key, kind = "values", reflect.Slice
+	}
+	if fcfVal.Kind() == reflect.Map {
+		container := fcfVal.MapIndex(reflect.ValueOf(key))
+		if !container.IsValid() || !container.Elem().IsValid() {
+			// protojson omits empty maps and arrays
+			if kind == reflect.Map {
+				return reflect.ValueOf(map[string]interface{}{}), nil
+			}
+			return reflect.ValueOf([]interface{}{}), nil
+		}
+		if container.Elem().Kind() == kind {
+			return container.Elem(), nil
+		}
 	}
-	return getMapFields(fcfVal, uVal, parentName)
+	return reflect.Value{}, fmt.Errorf("malformed firestore %s: missing %q", fcfType, key)
 }
 
-func unmarshal(fcfMap reflect.Value, usrVal fieldBag) error {
-	uVal, fields, err := getFields(fcfMap, usrVal)
+func (d *decodeState) unmarshal(fcfMap reflect.Value, usrVal fieldBag) error {
+	uVal, fields, err := d.getFields(fcfMap, usrVal)
 	if err != nil {
 		return err
 	}
 	for _, field := range fields {
-		fcfVal := field.Fcf()
-		err := assertTypeMatch(field.Type(), field.FcfType())
+		if err := d.unmarshalField(field); err != nil {
+			return err
+		}
+	}
+	usrVal.Set(uVal)
+	return nil
+}
+
+func (d *decodeState) unmarshalField(field field) error {
+	if ok, err := d.convertCustom(field); ok {
+		if err != nil {
+			return newDecodeError(field, err)
+		}
+		return nil
+	}
+	if ok, err := unmarshalCustom(field); ok {
+		if err != nil {
+			return newDecodeError(field, err)
+		}
+		return nil
+	}
+	if ok, err := unmarshalText(field); ok {
 		if err != nil {
-			return fmt.Errorf("Error unmarshalling field %s: %v", field.Name(), err)
+			return newDecodeError(field, err)
 		}
+		return nil
+	}
 
-		switch field.FcfType() {
-		case "mapValue":
-			fcfVal = fcfVal.MapIndex(reflect.ValueOf("fields")).Elem()
-		case "arrayValue":
-			fcfVal = fcfVal.MapIndex(reflect.ValueOf("values")).Elem()
-		case "geoPointValue":
-			// do nothing
-		default:
-			if err := setBasicType(field); err != nil {
-				return fmt.Errorf("Error setting field %s: %v", field.Name(), err)
+	if ok, err := d.unmarshalVector(field); ok {
+		if err != nil {
+			return newDecodeError(field, err)
+		}
+		return nil
+	}
+
+	fcfVal := field.Fcf()
+	err := assertTypeMatch(field.Type(), field.FcfType())
+	if err != nil && !(d.lossyNumbers && field.FcfType() == "doubleValue" && isIntKind(elemKind(field.Type()))) {
+		return newDecodeError(field, err)
+	}
+
+	switch field.FcfType() {
+	case "mapValue", "arrayValue":
+		fcfVal, err = getContainer(fcfVal, field.FcfType())
+		if err != nil {
+			return newDecodeError(field, err)
+		}
+	case "geoPointValue":
+		if fcfVal.Kind() != reflect.Map {
+			return newDecodeError(field, errors.New("malformed firestore geoPointValue"))
+		}
+		if field.Type().Kind() == reflect.Interface {
+			// decode into a GeoPoint for the interface to hold
+			var p GeoPoint
+			geoPoint := structField{
+				name:    field.Name(),
+				fcfPath: field.FcfPath(),
+				fcfType: field.FcfType(),
+				fcf:     fcfVal,
+				val:     reflect.ValueOf(&p).Elem(),
 			}
-			continue
+			if err := d.unmarshal(fcfVal, geoPoint); err != nil {
+				return err
+			}
+			field.Set(reflect.ValueOf(p))
+			return nil
 		}
-		if err := unmarshal(fcfVal, field); err != nil {
-			return err
+	default:
+		if err := d.setBasicType(field); err != nil {
+			return newDecodeError(field, err)
 		}
+		return nil
 	}
-	usrVal.Set(uVal)
-	return nil
+	return d.unmarshal(fcfVal, field)
+}
+
+// decodeDynamic decodes a single wrapped firestore value
+// into the same interface{} representation Decode would produce
+func (d *decodeState) decodeDynamic(wrapped interface{}, name string) (interface{}, error) {
+	var usrVal interface{}
+	fieldVal := reflect.ValueOf(&usrVal).Elem()
+	fcfVal, fcfType, err := unwrapFcfVal(reflect.ValueOf(&wrapped).Elem())
+	if err != nil {
+		return nil, &DecodeError{Path: name, GoType: fieldVal.Type(), Err: err}
+	}
+	err = d.unmarshalField(structField{
+		name:    name,
+		fcfPath: name,
+		fcfType: fcfType,
+		fcf:     fcfVal,
+		val:     fieldVal,
+	})
+	return usrVal, err
 }
 
 // Conversions
 
-func setBasicType(field field) error {
+func (d *decodeState) setBasicType(field field) error {
 	fcfVal := field.Fcf()
 	fieldType := field.Type()
+	if field.FcfType() == "nullValue" {
+		field.Set(reflect.Zero(fieldType))
+		return nil
+	}
+	if !fcfVal.IsValid() {
+		return errMissingValue
+	}
+	isPtr := fieldType.Kind() == reflect.Ptr
+	if isPtr {
+		fieldType = fieldType.Elem()
+	}
+
+	if fieldType.Kind() == reflect.Interface {
+		fieldType = d.dynamicType(field.FcfType(), fcfVal, fieldType)
+	}
+
+	var err error
 	switch field.FcfType() {
+	case "":
+		// the raw members of a geoPointValue are doubles,
+		// which protojson may also send as strings
+		if k := fieldType.Kind(); k == reflect.Float32 || k == reflect.Float64 {
+			fcfVal, err = convDoubleToFloat(fcfVal)
+		}
+
 	case "referenceValue":
-		fcfVal = convReference(fcfVal)
+		fcfVal, err = convReference(fcfVal, fieldType)
 
 	case "timestampValue":
-		fcfVal = convTimestamp(fcfVal)
+		fcfVal, err = convTimestamp(fcfVal)
 
 	case "bytesValue":
-		fcfVal = convBytes(fcfVal)
-
-	case "nullValue":
-		fcfVal = reflect.Zero(fieldType)
+		fcfVal, err = convBytes(fcfVal)
 
 	case "integerValue":
-		if fieldType.Kind() == reflect.Interface {
-			fieldType = reflect.TypeOf(0)
+		switch {
+		case fieldType == bigIntType:
+			fcfVal, err = convBigInt(fcfVal)
+		case fieldType == bigFloatType:
+			fcfVal, err = convIntegerToBigFloat(fcfVal)
+		case fieldType.Kind() == reflect.String:
+			fcfVal, err = convIntegerToString(fcfVal)
+		case fieldType.Kind() <= reflect.Int64:
+			fcfVal, err = convInt(fcfVal, fieldType, d.lossyNumbers)
+		case fieldType.Kind() <= reflect.Uintptr:
+			fcfVal, err = convUint(fcfVal, fieldType, d.lossyNumbers)
+		default:
+			fcfVal, err = convIntegerToFloat(fcfVal, fieldType, d.lossyNumbers)
 		}
-		bits := fieldType.Bits()
-		if fieldType.Kind() <= reflect.Int64 || fieldType.Kind() == reflect.Interface {
-			fcfVal = convInt(fcfVal, bits)
-		} else if fieldType.Kind() <= reflect.Uintptr {
-			fcfVal = convUint(fcfVal, bits)
-		} else {
-			fcfVal = convIntegerToFloat(fcfVal, bits)
+
+	case "doubleValue":
+		switch {
+		case fieldType == bigFloatType:
+			fcfVal, err = convDoubleToBigFloat(fcfVal)
+		case fieldType == jsonNumberType:
+			fcfVal, err = convDoubleToJSONNumber(fcfVal)
+		case fieldType.Kind() == reflect.String:
+			fcfVal, err = convDoubleToString(fcfVal)
+		case fieldType.Kind() == reflect.Float32:
+			fcfVal, err = convDoubleToFloat32(fcfVal, fieldType, d.lossyNumbers)
+		case isIntKind(fieldType.Kind()):
+			fcfVal, err = convDoubleToInt(fcfVal, fieldType)
+		default:
+			fcfVal, err = convDoubleToFloat(fcfVal)
 		}
 	}
+	if err != nil {
+		return err
+	}
 
+	if !fcfVal.Type().ConvertibleTo(fieldType) {
+		return fmt.Errorf("Cannot convert %v to %v", fcfVal.Type(), fieldType)
+	}
 	fcfVal = fcfVal.Convert(fieldType)
+	if isPtr {
+		ptr := reflect.New(fieldType)
+		ptr.Elem().Set(fcfVal)
+		fcfVal = ptr
+	}
 	field.Set(fcfVal)
 	return nil
 }
 
-func convBytes(fcfVal reflect.Value) reflect.Value {
-	data, err := base64.StdEncoding.DecodeString(fcfVal.String())
+// errMissingValue reports a typed value whose payload is absent or null
+var errMissingValue = errors.New("missing value")
+
+func convString(fcfVal reflect.Value) (string, error) {
+	if !fcfVal.IsValid() {
+		return "", errMissingValue
+	}
+	if fcfVal.Kind() != reflect.String {
+		return "", fmt.Errorf("expected a string, got %v", fcfVal.Type())
+	}
+	return fcfVal.String(), nil
+}
+
+func convBytes(fcfVal reflect.Value) (reflect.Value, error) {
+	s, err := convString(fcfVal)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	// accept any base64 the protobuf JSON mapping does
+	enc := base64.StdEncoding
+	if strings.ContainsAny(s, "-_") {
+		enc = base64.URLEncoding
+	}
+	if len(s)%4 != 0 {
+		enc = enc.WithPadding(base64.NoPadding)
+	}
+	data, err := enc.DecodeString(s)
 	if err != nil {
-		panic(err) //shouldn't happen
+		return reflect.Value{}, err
 	}
-	return reflect.ValueOf(data)
+	return reflect.ValueOf(data), nil
 }
 
-func convReference(fcfVal reflect.Value) reflect.Value {
-	return reflect.ValueOf(strings.Split(fcfVal.String(), "/databases/(default)/documents")[1])
+// convReference returns a DocumentRef if that's what the user asked for,
+// otherwise it returns the document path relative to the database root
+func convReference(fcfVal reflect.Value, fieldType reflect.Type) (reflect.Value, error) {
+	s, err := convString(fcfVal)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	ref, err := ParseDocumentRef(s)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if fieldType == documentRefType {
+		return reflect.ValueOf(ref), nil
+	}
+	return reflect.ValueOf("/" + ref.Path), nil
 }
 
-func convTimestamp(fcfVal reflect.Value) reflect.Value {
-	t, err := time.Parse(time.RFC3339Nano, fcfVal.String())
+func convTimestamp(fcfVal reflect.Value) (reflect.Value, error) {
+	s, err := convString(fcfVal)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
 	if err != nil {
-		panic(err)
+		return reflect.Value{}, err
 	}
-	return reflect.ValueOf(t)
+	return reflect.ValueOf(t), nil
 }
 
-func convInt(fcfVal reflect.Value, bits int) reflect.Value {
-	val, err := strconv.ParseInt(fcfVal.String(), 0, bits)
+// isRangeErr reports whether err is a strconv error for an out of range number
+func isRangeErr(err error) bool {
+	var numErr *strconv.NumError
+	return errors.As(err, &numErr) && numErr.Err == strconv.ErrRange
+}
+
+// convInt parses an integer for an int field of type t.
+// If lossy, integers out of t's range are saturated.
+func convInt(fcfVal reflect.Value, t reflect.Type, lossy bool) (reflect.Value, error) {
+	s, err := convInteger(fcfVal)
 	if err != nil {
-		panic(err) // shouldn't ever happen?
+		return reflect.Value{}, err
+	}
+	// on a range error, ParseInt returns the saturated value
+	val, err := strconv.ParseInt(s, 10, t.Bits())
+	if isRangeErr(err) {
+		if !lossy {
+			return reflect.Value{}, &RangeError{Value: s, GoType: t, Err: err}
+		}
+		err = nil
 	}
-	return reflect.ValueOf(val)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(val), nil
 }
 
-func convUint(fcfVal reflect.Value, bits int) reflect.Value {
-	val, err := strconv.ParseUint(fcfVal.String(), 0, bits)
+// convUint parses an integer for a uint field of type t.
+// If lossy, integers out of t's range are saturated.
+func convUint(fcfVal reflect.Value, t reflect.Type, lossy bool) (reflect.Value, error) {
+	s, err := convInteger(fcfVal)
 	if err != nil {
-		panic(err) // shouldn't ever happen?
+		return reflect.Value{}, err
+	}
+	if strings.HasPrefix(s, "-") {
+		if _, err := strconv.ParseInt(s, 10, 64); err != nil && !isRangeErr(err) {
+			return reflect.Value{}, err
+		}
+		if !lossy {
+			return reflect.Value{}, &RangeError{Value: s, GoType: t}
+		}
+		return reflect.ValueOf(uint64(0)), nil
+	}
+	// on a range error, ParseUint returns the saturated value
+	val, err := strconv.ParseUint(s, 10, t.Bits())
+	if isRangeErr(err) {
+		if !lossy {
+			return reflect.Value{}, &RangeError{Value: s, GoType: t, Err: err}
+		}
+		err = nil
 	}
-	return reflect.ValueOf(val)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(val), nil
 }
 
-func convIntegerToFloat(fcfVal reflect.Value, bits int) reflect.Value {
-	val, err := strconv.ParseFloat(fcfVal.String(), bits)
+// convIntegerToFloat parses an integer for a float field of type t.
+// If lossy, integers out of t's range are saturated.
+func convIntegerToFloat(fcfVal reflect.Value, t reflect.Type, lossy bool) (reflect.Value, error) {
+	s, err := convInteger(fcfVal)
 	if err != nil {
-		panic(err) // shouldn't ever happen?
+		return reflect.Value{}, err
+	}
+	val, err := strconv.ParseFloat(s, t.Bits())
+	if isRangeErr(err) {
+		if !lossy {
+			return reflect.Value{}, &RangeError{Value: s, GoType: t, Err: err}
+		}
+		return reflect.ValueOf(saturateFloat(val, t)), nil
+	}
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(val), nil
+}
+
+// saturateFloat clamps the finite or infinite f to the finite range of t
+func saturateFloat(f float64, t reflect.Type) float64 {
+	max := math.MaxFloat64
+	if t.Bits() == 32 {
+		max = math.MaxFloat32
+	}
+	return math.Max(-max, math.Min(max, f))
+}
+
+// convDoubleToFloat32 checks that a finite double fits in a float32 field of type t.
+// If lossy, doubles out of range are saturated.
+func convDoubleToFloat32(fcfVal reflect.Value, t reflect.Type, lossy bool) (reflect.Value, error) {
+	f, err := convDouble(fcfVal)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if !math.IsInf(f, 0) && math.Abs(f) > math.MaxFloat32 {
+		if !lossy {
+			return reflect.Value{}, &RangeError{Value: strconv.FormatFloat(f, 'g', -1, 64), GoType: t}
+		}
+		f = saturateFloat(f, t)
+	}
+	return reflect.ValueOf(f), nil
+}
+
+// convDoubleToInt truncates a double towards zero for an int or uint
+// field of type t, saturating it if it's out of range. It's only used
+// when lossy conversions are allowed.
+func convDoubleToInt(fcfVal reflect.Value, t reflect.Type) (reflect.Value, error) {
+	f, err := convDouble(fcfVal)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if math.IsNaN(f) {
+		return reflect.Value{}, fmt.Errorf("Cannot convert NaN to %v", t)
+	}
+	f = math.Trunc(f)
+	if t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uintptr {
+		max := math.Ldexp(1, t.Bits())
+		if f <= 0 {
+			return reflect.ValueOf(uint64(0)), nil
+		} else if f >= max {
+			return reflect.ValueOf(uint64(math.MaxUint64) >> uint(64-t.Bits())), nil
+		}
+		return reflect.ValueOf(uint64(f)), nil
+	}
+	limit := math.Ldexp(1, t.Bits()-1)
+	max := int64(uint64(math.MaxUint64) >> uint(65-t.Bits()))
+	if f < -limit {
+		return reflect.ValueOf(-max - 1), nil
+	} else if f >= limit {
+		return reflect.ValueOf(max), nil
+	}
+	return reflect.ValueOf(int64(f)), nil
+}
+
+func convBigInt(fcfVal reflect.Value) (reflect.Value, error) {
+	s, err := convInteger(fcfVal)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	val, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("invalid integer %q", s)
+	}
+	return reflect.ValueOf(val).Elem(), nil
+}
+
+// convIntegerToBigFloat returns a big.Float precise enough to hold the integer exactly
+func convIntegerToBigFloat(fcfVal reflect.Value) (reflect.Value, error) {
+	i, err := convBigInt(fcfVal)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	val := new(big.Float).SetInt(i.Addr().Interface().(*big.Int))
+	return reflect.ValueOf(val).Elem(), nil
+}
+
+// convIntegerToString returns the integer's decimal digits,
+// which also suits json.Number fields
+func convIntegerToString(fcfVal reflect.Value) (reflect.Value, error) {
+	i, err := convBigInt(fcfVal)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(i.Addr().Interface().(*big.Int).String()), nil
+}
+
+// convDouble accepts a double as a JSON number or, as the protobuf
+// JSON mapping allows, as a string such as "NaN", "Infinity" or "-Infinity"
+func convDouble(fcfVal reflect.Value) (float64, error) {
+	if !fcfVal.IsValid() {
+		return 0, errMissingValue
+	}
+	switch fcfVal.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return fcfVal.Float(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fcfVal.Int()), nil
+	case reflect.String:
+		switch s := fcfVal.String(); s {
+		case "NaN":
+			return math.NaN(), nil
+		case "Infinity":
+			return math.Inf(1), nil
+		case "-Infinity":
+			return math.Inf(-1), nil
+		default:
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+				return 0, fmt.Errorf("invalid double %q", s)
+			}
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("expected a number, got %v", fcfVal.Type())
+}
+
+// convInteger returns the text of an integer, which the protobuf
+// JSON mapping sends as a string but also accepts as a JSON number
+func convInteger(fcfVal reflect.Value) (string, error) {
+	if !fcfVal.IsValid() {
+		return "", errMissingValue
+	}
+	switch fcfVal.Kind() {
+	case reflect.String:
+		return fcfVal.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(fcfVal.Int(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		f := fcfVal.Float()
+		if math.IsInf(f, 0) || f != math.Trunc(f) {
+			return "", fmt.Errorf("expected an integer, got %v", f)
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("expected a string, got %v", fcfVal.Type())
+}
+
+// convDoubleToFloat accepts any double for a float64 field
+func convDoubleToFloat(fcfVal reflect.Value) (reflect.Value, error) {
+	f, err := convDouble(fcfVal)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(f), nil
+}
+
+func convDoubleToBigFloat(fcfVal reflect.Value) (reflect.Value, error) {
+	f, err := convDouble(fcfVal)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if math.IsNaN(f) {
+		return reflect.Value{}, errors.New("Cannot store NaN in a big.Float")
+	}
+	return reflect.ValueOf(big.NewFloat(f)).Elem(), nil
+}
+
+// formatDouble returns the shortest decimal that parses back to f,
+// or the protobuf JSON mapping's name for it if it isn't finite
+func formatDouble(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// convDoubleToString returns the double's text, as formatDouble does
+func convDoubleToString(fcfVal reflect.Value) (reflect.Value, error) {
+	f, err := convDouble(fcfVal)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(formatDouble(f)), nil
+}
+
+// convDoubleToJSONNumber returns the double's text,
+// failing for the non-finite doubles json.Number can't hold
+func convDoubleToJSONNumber(fcfVal reflect.Value) (reflect.Value, error) {
+	f, err := convDouble(fcfVal)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return reflect.Value{}, fmt.Errorf("Cannot store %v in a json.Number", f)
 	}
-	return reflect.ValueOf(val)
+	return reflect.ValueOf(formatDouble(f)), nil
 }
 
 // Helpers
@@ -3,7 +3,12 @@ package fcf
 import (
 	"bytes"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"math"
+	"math/big"
+	"net"
+	"reflect"
 	"strconv"
 	"testing"
 	"time"
@@ -271,6 +276,137 @@ func TestDecimal(t *testing.T) {
 	}
 }
 
+func TestBigNumbers(t *testing.T) {
+	const huge = "123456789012345678901234567890"
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"BigInt":       map[string]interface{}{"integerValue": huge},
+			"BigFloat":     map[string]interface{}{"integerValue": huge},
+			"BigFloatDbl":  map[string]interface{}{"doubleValue": 2.5},
+			"Number":       map[string]interface{}{"integerValue": "9007199254740993"},
+			"NumberDbl":    map[string]interface{}{"doubleValue": 0.1},
+			"String":       map[string]interface{}{"integerValue": "-42"},
+			"StringDbl":    map[string]interface{}{"doubleValue": 1e21},
+			"BigIntValue":  map[string]interface{}{"integerValue": "7"},
+			"NumberPtr":    map[string]interface{}{"integerValue": "8"},
+			"BigFloatNull": map[string]interface{}{"nullValue": nil},
+		},
+	}
+	userVal := &struct {
+		BigInt       *big.Int
+		BigFloat     *big.Float
+		BigFloatDbl  *big.Float
+		Number       json.Number
+		NumberDbl    json.Number
+		String       string
+		StringDbl    string
+		BigIntValue  big.Int
+		NumberPtr    *json.Number
+		BigFloatNull *big.Float
+	}{}
+	if err := fcfVal.Decode(userVal); err != nil {
+		t.Fatal(err)
+	}
+	if userVal.BigInt == nil || userVal.BigInt.String() != huge {
+		t.Errorf("expected %s, got %v", huge, userVal.BigInt)
+	}
+	if userVal.BigFloat == nil || userVal.BigFloat.Text('f', 0) != huge {
+		t.Errorf("expected %s, got %v", huge, userVal.BigFloat)
+	}
+	if userVal.BigFloatDbl == nil || userVal.BigFloatDbl.String() != "2.5" {
+		t.Errorf("expected 2.5, got %v", userVal.BigFloatDbl)
+	}
+	if userVal.Number != "9007199254740993" || userVal.NumberDbl != "0.1" {
+		t.Errorf("expected 9007199254740993 and 0.1, got %v and %v", userVal.Number, userVal.NumberDbl)
+	}
+	if userVal.String != "-42" || userVal.StringDbl != "1e+21" {
+		t.Errorf("expected -42 and 1e+21, got %v and %v", userVal.String, userVal.StringDbl)
+	}
+	if userVal.BigIntValue.Int64() != 7 {
+		t.Errorf("expected 7, got %v", &userVal.BigIntValue)
+	}
+	if userVal.NumberPtr == nil || *userVal.NumberPtr != "8" {
+		t.Errorf("expected 8, got %v", userVal.NumberPtr)
+	}
+	if userVal.BigFloatNull != nil {
+		t.Errorf("expected nil, got %v", userVal.BigFloatNull)
+	}
+
+	var intVal struct{ BigInt *big.Int }
+	fcfVal = Value{
+		Fields: map[string]interface{}{
+			"BigInt": map[string]interface{}{"doubleValue": 1.5},
+		},
+	}
+	if err := fcfVal.Decode(&intVal); err == nil {
+		t.Error("expected an error decoding a doubleValue into a big.Int")
+	}
+}
+
+func TestNonFiniteDoubles(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"NaN":      map[string]interface{}{"doubleValue": "NaN"},
+			"Inf":      map[string]interface{}{"doubleValue": "Infinity"},
+			"NegInf":   map[string]interface{}{"doubleValue": "-Infinity"},
+			"Float32":  map[string]interface{}{"doubleValue": "-Infinity"},
+			"Quoted":   map[string]interface{}{"doubleValue": "1.5e3"},
+			"Dynamic":  map[string]interface{}{"doubleValue": "Infinity"},
+			"String":   map[string]interface{}{"doubleValue": "NaN"},
+			"IntNum":   map[string]interface{}{"integerValue": float64(42)},
+			"IntFloat": map[string]interface{}{"integerValue": float64(-7)},
+		},
+	}
+	userVal := &struct {
+		NaN      float64
+		Inf      float64
+		NegInf   *float64
+		Float32  float32
+		Quoted   float64
+		Dynamic  interface{}
+		String   string
+		IntNum   int
+		IntFloat float64
+	}{}
+	if err := fcfVal.Decode(userVal); err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsNaN(userVal.NaN) {
+		t.Errorf("expected NaN, got %v", userVal.NaN)
+	}
+	if !math.IsInf(userVal.Inf, 1) || userVal.NegInf == nil || !math.IsInf(*userVal.NegInf, -1) {
+		t.Errorf("expected +Inf and -Inf, got %v and %v", userVal.Inf, userVal.NegInf)
+	}
+	if !math.IsInf(float64(userVal.Float32), -1) {
+		t.Errorf("expected -Inf, got %v", userVal.Float32)
+	}
+	if userVal.Quoted != 1500 {
+		t.Errorf("expected 1500, got %v", userVal.Quoted)
+	}
+	if f, ok := userVal.Dynamic.(float64); !ok || !math.IsInf(f, 1) {
+		t.Errorf("expected +Inf float64, got %#v", userVal.Dynamic)
+	}
+	if userVal.String != "NaN" {
+		t.Errorf("expected %q, got %q", "NaN", userVal.String)
+	}
+	if userVal.IntNum != 42 || userVal.IntFloat != -7 {
+		t.Errorf("expected 42 and -7, got %v and %v", userVal.IntNum, userVal.IntFloat)
+	}
+
+	for _, raw := range []interface{}{"nan", "inf", "1e400", "x", true} {
+		fcfVal := Value{Fields: map[string]interface{}{"F": map[string]interface{}{"doubleValue": raw}}}
+		var f struct{ F float64 }
+		if err := fcfVal.Decode(&f); err == nil {
+			t.Errorf("expected an error decoding doubleValue %#v", raw)
+		}
+	}
+	fcfVal = Value{Fields: map[string]interface{}{"I": map[string]interface{}{"integerValue": 1.5}}}
+	var i struct{ I int }
+	if err := fcfVal.Decode(&i); err == nil {
+		t.Error("expected an error decoding integerValue 1.5")
+	}
+}
+
 func TestTimestamp(t *testing.T) {
 	testVal := time.Date(2019, time.February, 3, 1, 7, 5, 565000000, time.UTC)
 	fcfVal := Value{
@@ -329,6 +465,30 @@ func TestGeoPoint(t *testing.T) {
 	}
 }
 
+func TestGeoPointStrings(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"Field": map[string]interface{}{
+				"geoPointValue": map[string]interface{}{"latitude": "1.5", "longitude": "-Infinity"},
+			},
+		},
+	}
+	var userVal struct {
+		Field GeoPoint
+		Node  Node `fcf:"Field"`
+	}
+	if err := fcfVal.Decode(&userVal); err != nil {
+		t.Fatal(err)
+	}
+	expected := GeoPoint{Latitude: 1.5, Longitude: math.Inf(-1)}
+	if userVal.Field != expected {
+		t.Errorf("expected %+v, got %+v", expected, userVal.Field)
+	}
+	if p, _ := userVal.Node.AsGeoPoint(); p != expected {
+		t.Errorf("expected node %+v, got %+v", expected, p)
+	}
+}
+
 func TestBytes(t *testing.T) {
 	testVal := []byte("foobar")
 	fcfVal := Value{
@@ -429,6 +589,37 @@ func TestArray(t *testing.T) {
 	}
 }
 
+func TestArrayReuse(t *testing.T) {
+	array := func(values ...string) Value {
+		wrapped := make([]interface{}, len(values))
+		for i, v := range values {
+			wrapped[i] = map[string]interface{}{"stringValue": v}
+		}
+		return Value{Fields: map[string]interface{}{
+			"Field": map[string]interface{}{"arrayValue": map[string]interface{}{"values": wrapped}},
+		}}
+	}
+
+	var userVal struct{ Field []string }
+	for _, values := range [][]string{{"a"}, {"b", "c", "d"}, {"e", "f"}, {}} {
+		if err := array(values...).Decode(&userVal); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(userVal.Field, values) {
+			t.Errorf("expected %v, got %v", values, userVal.Field)
+		}
+	}
+
+	var dynamic struct{ Field interface{} }
+	dynamic.Field = "not a slice"
+	if err := array("a").Decode(&dynamic); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dynamic.Field, []interface{}{"a"}) {
+		t.Errorf("expected [a], got %v", dynamic.Field)
+	}
+}
+
 func TestMapAsStruct(t *testing.T) {
 	key0, key1, key2 := "Elem0", "Elem1", "Elem2"
 	val0, val1, val2 := "foo", "bar", "baz"
@@ -882,3 +1073,140 @@ func TestMapNestedOuterDynamicInnerStaticPtr(t *testing.T) {
 		t.Errorf("M[\"Inner\"].%s: expected %q, got %q", key2, val2, userVal.M["Inner"].Elem2)
 	}
 }
+
+func TestDecodeError(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"Outer": map[string]interface{}{
+				"mapValue": map[string]interface{}{
+					"fields": map[string]interface{}{
+						"Inner": map[string]interface{}{
+							"arrayValue": map[string]interface{}{
+								"values": []interface{}{
+									map[string]interface{}{"integerValue": "1"},
+									map[string]interface{}{"integerValue": "300"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	userVal := &struct {
+		Outer struct {
+			Inner []int8
+		}
+	}{}
+	err := fcfVal.Decode(userVal)
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("expected a *DecodeError, got %v", err)
+	}
+	if decodeErr.Path != "Outer.Inner[1]" {
+		t.Errorf("expected path %q, got %q", "Outer.Inner[1]", decodeErr.Path)
+	}
+	if decodeErr.FcfType != "integerValue" {
+		t.Errorf("expected fcf type %q, got %q", "integerValue", decodeErr.FcfType)
+	}
+	if decodeErr.GoType != reflect.TypeOf(int8(0)) {
+		t.Errorf("expected go type %v, got %v", reflect.TypeOf(int8(0)), decodeErr.GoType)
+	}
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) {
+		t.Errorf("expected cause to be a *strconv.NumError, got %v", decodeErr.Err)
+	}
+}
+
+func TestRangeErrors(t *testing.T) {
+	tests := []struct {
+		fcfVal interface{}
+		usrVal interface{}
+	}{
+		{map[string]interface{}{"integerValue": "128"}, new(int8)},
+		{map[string]interface{}{"integerValue": "-129"}, new(int8)},
+		{map[string]interface{}{"integerValue": "70000"}, new(uint16)},
+		{map[string]interface{}{"integerValue": "-1"}, new(uint)},
+		{map[string]interface{}{"integerValue": "9223372036854775808"}, new(int64)},
+		{map[string]interface{}{"integerValue": "1e400"}, new(float64)},
+		{map[string]interface{}{"doubleValue": 1e39}, new(float32)},
+		{map[string]interface{}{"doubleValue": -1e39}, new(*float32)},
+	}
+	for _, test := range tests {
+		fcfVal := Value{Fields: map[string]interface{}{"Field": test.fcfVal}}
+		userVal := reflect.New(reflect.StructOf([]reflect.StructField{
+			{Name: "Field", Type: reflect.TypeOf(test.usrVal).Elem()},
+		}))
+		err := fcfVal.Decode(userVal.Interface())
+		var rangeErr *RangeError
+		if !errors.As(err, &rangeErr) {
+			t.Errorf("%v into %T: expected a *RangeError, got %v", test.fcfVal, test.usrVal, err)
+		}
+	}
+}
+
+func TestMalformedValues(t *testing.T) {
+	malformed := map[string]interface{}{
+		"bytes":     map[string]interface{}{"bytesValue": "not base64!"},
+		"timestamp": map[string]interface{}{"timestampValue": "yesterday"},
+		"integer":   map[string]interface{}{"integerValue": true},
+		"reference": map[string]interface{}{"referenceValue": "col1/doc1"},
+		"twoTypes":  map[string]interface{}{"stringValue": "foo", "booleanValue": true},
+		"map":       map[string]interface{}{"mapValue": "foo"},
+		"array":     map[string]interface{}{"arrayValue": map[string]interface{}{"values": "foo"}},
+	}
+	for name, wrapped := range malformed {
+		fcfVal := Value{Fields: map[string]interface{}{"Field": wrapped}}
+		userVal := &struct {
+			Field interface{}
+		}{}
+		err := fcfVal.Decode(userVal)
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Errorf("%s: expected a *DecodeError, got %v", name, err)
+		}
+	}
+}
+
+func TestNullPayloads(t *testing.T) {
+	nullString := map[string]interface{}{"stringValue": nil}
+	nullVector := vectorValue(map[string]interface{}{"doubleValue": nil})
+	tests := []struct {
+		name    string
+		wrapped map[string]interface{}
+		userVal interface{}
+	}{
+		{"textUnmarshaler", nullString, &struct{ Field net.IP }{}},
+		{"node", map[string]interface{}{"integerValue": nil}, &struct{ Field Node }{}},
+		{"float32Vector", nullVector, &struct{ Field []float32 }{}},
+		{"dynamicVector", nullVector, &struct{ Field interface{} }{}},
+	}
+	for _, test := range tests {
+		fcfVal := Value{Fields: map[string]interface{}{"Field": test.wrapped}}
+		err := fcfVal.Decode(test.userVal)
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Errorf("%s: expected a *DecodeError, got %v", test.name, err)
+		}
+	}
+
+	fcfVal := Value{Fields: map[string]interface{}{"Field": nullString}}
+	if _, err := fcfVal.GetString("Field"); err == nil {
+		t.Errorf("expected an error getting a null string payload")
+	}
+	oldVal := Value{Fields: map[string]interface{}{"Field": vectorValue(map[string]interface{}{"doubleValue": 1.0})}}
+	newVal := Value{Fields: map[string]interface{}{"Field": nullVector}}
+	if _, err := Diff(oldVal, newVal); err == nil {
+		t.Errorf("expected an error diffing a null vector element")
+	}
+}
+
+func TestDecodeNonPointer(t *testing.T) {
+	fcfVal := Value{Fields: map[string]interface{}{}}
+	var userVal struct{ Field string }
+	var decodeErr *DecodeError
+	if err := fcfVal.Decode(userVal); !errors.As(err, &decodeErr) {
+		t.Errorf("expected a *DecodeError, got %v", err)
+	}
+}
@@ -0,0 +1,223 @@
+package fcf
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FieldPath is a parsed Firestore field path. Each element is one
+// field name, so FieldPath{"address", "city"} is the path address.city
+type FieldPath []string
+
+// ParseFieldPath parses a field path in Firestore syntax.
+// Field names are separated by dots, and names that are not simple
+// identifiers may be quoted with backticks, within which
+// a backslash escapes the following character (e.g. `a.b`.`c\`d`)
+func ParseFieldPath(s string) (FieldPath, error) {
+	var path FieldPath
+	for i := 0; ; {
+		name, n, err := parseFieldName(s[i:])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid field path %q: %v", s, err)
+		}
+		path = append(path, name)
+		i += n
+		if i == len(s) {
+			return path, nil
+		}
+		if s[i] != '.' {
+			return nil, fmt.Errorf("Invalid field path %q: unexpected %q at offset %d", s, s[i], i)
+		}
+		i++
+	}
+}
+
+// parseValuePath parses the path of a value within a document:
+// a field path whose field names may be followed by array indexes,
+// e.g. address.lines[0], in the syntax of Change.Path. It returns
+// a string for each field name and an int for each index.
+func parseValuePath(s string) ([]interface{}, error) {
+	var elems []interface{}
+	for i := 0; ; {
+		name, n, err := parseFieldName(s[i:])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid path %q: %v", s, err)
+		}
+		elems = append(elems, name)
+		i += n
+		for i < len(s) && s[i] == '[' {
+			end := strings.IndexByte(s[i:], ']')
+			if end == -1 {
+				return nil, fmt.Errorf("Invalid path %q: unterminated index at offset %d", s, i)
+			}
+			index, err := strconv.Atoi(s[i+1 : i+end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("Invalid path %q: invalid index %q at offset %d", s, s[i+1:i+end], i)
+			}
+			elems = append(elems, index)
+			i += end + 1
+		}
+		if i == len(s) {
+			return elems, nil
+		}
+		if s[i] != '.' {
+			return nil, fmt.Errorf("Invalid path %q: unexpected %q at offset %d", s, s[i], i)
+		}
+		i++
+	}
+}
+
+// parseFieldName reads one field name from the start of s
+// and returns it along with the number of bytes consumed
+func parseFieldName(s string) (name string, n int, err error) {
+	if strings.HasPrefix(s, "`") {
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '`':
+				if b.Len() == 0 {
+					return "", 0, errors.New("empty field name")
+				}
+				return b.String(), i + 1, nil
+			case '\\':
+				i++
+				if i == len(s) {
+					return "", 0, errors.New("unterminated escape")
+				}
+			}
+			b.WriteByte(s[i])
+		}
+		return "", 0, errors.New("unterminated backtick")
+	}
+	n = strings.IndexAny(s, ".`\\[]")
+	if n == -1 {
+		n = len(s)
+	}
+	if n == 0 {
+		return "", 0, errors.New("empty field name")
+	}
+	return s[:n], n, nil
+}
+
+func isSimpleFieldName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		if c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || (i > 0 && '0' <= c && c <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+func quoteFieldName(name string) string {
+	if isSimpleFieldName(name) {
+		return name
+	}
+	name = strings.Replace(name, "\\", "\\\\", -1)
+	name = strings.Replace(name, "`", "\\`", -1)
+	return "`" + name + "`"
+}
+
+// String returns the path in Firestore syntax,
+// quoting any field names that are not simple identifiers
+func (p FieldPath) String() string {
+	quoted := make([]string, len(p))
+	for i, name := range p {
+		quoted[i] = quoteFieldName(name)
+	}
+	return strings.Join(quoted, ".")
+}
+
+// Equal reports whether p and q are the same path
+func (p FieldPath) Equal(q FieldPath) bool {
+	return len(p) == len(q) && p.HasPrefix(q)
+}
+
+// HasPrefix reports whether p equals prefix or is nested beneath it
+func (p FieldPath) HasPrefix(prefix FieldPath) bool {
+	if len(prefix) > len(p) {
+		return false
+	}
+	for i := range prefix {
+		if p[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ChangedFields returns the paths written by the event.
+// For updates these come from the UpdateMask. Creates and deletes
+// have no mask, so every top level field of the document is returned.
+func (e Event) ChangedFields() []FieldPath {
+	switch e.Kind() {
+	case Update:
+		paths := make([]FieldPath, 0, len(e.UpdateMask.FieldPaths))
+		for _, s := range e.UpdateMask.FieldPaths {
+			path, err := ParseFieldPath(s)
+			if err != nil {
+				// treat unparseable mask entries as a literal name
+				path = FieldPath{s}
+			}
+			paths = append(paths, path)
+		}
+		return paths
+	case Create:
+		return topLevelPaths(e.Value)
+	case Delete:
+		return topLevelPaths(e.OldValue)
+	}
+	return nil
+}
+
+func topLevelPaths(v Value) []FieldPath {
+	keys := make([]string, 0, len(v.Fields))
+	for key := range v.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	paths := make([]FieldPath, len(keys))
+	for i, key := range keys {
+		paths[i] = FieldPath{key}
+	}
+	return paths
+}
+
+// Changed reports whether the field at path was written by the event,
+// either directly or because one of its ancestors was written.
+// Use ChangedUnder to also detect writes to fields nested beneath path.
+// It returns false if path is not a valid field path.
+func (e Event) Changed(path string) bool {
+	p, err := ParseFieldPath(path)
+	if err != nil {
+		return false
+	}
+	for _, changed := range e.ChangedFields() {
+		if p.HasPrefix(changed) {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangedUnder reports whether the event wrote the field at prefix,
+// one of its ancestors, or any field nested beneath it.
+// It returns false if prefix is not a valid field path.
+func (e Event) ChangedUnder(prefix string) bool {
+	p, err := ParseFieldPath(prefix)
+	if err != nil {
+		return false
+	}
+	for _, changed := range e.ChangedFields() {
+		if p.HasPrefix(changed) || changed.HasPrefix(p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package fcf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFieldPath(t *testing.T) {
+	tests := map[string]FieldPath{
+		"name":                  {"name"},
+		"address.city":          {"address", "city"},
+		"`a.b`.c":               {"a.b", "c"},
+		"`with space`":          {"with space"},
+		"`back\\`tick`.x":       {"back`tick", "x"},
+		"`back\\\\slash`":       {"back\\slash"},
+		"a.`b`.`c.d`":           {"a", "b", "c.d"},
+		"snake_case.kebab-case": {"snake_case", "kebab-case"},
+	}
+	for s, expected := range tests {
+		path, err := ParseFieldPath(s)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+		if !reflect.DeepEqual(path, expected) {
+			t.Errorf("%s: expected %#v, got %#v", s, expected, path)
+		}
+	}
+}
+
+func TestParseFieldPathErrors(t *testing.T) {
+	for _, s := range []string{"", ".", "a.", ".a", "a..b", "`a", "``", "`a`b", "`a\\", "a`b`"} {
+		if path, err := ParseFieldPath(s); err == nil {
+			t.Errorf("%s: expected error, got %#v", s, path)
+		}
+	}
+}
+
+func TestParseValuePath(t *testing.T) {
+	tests := map[string][]interface{}{
+		"name":            {"name"},
+		"a.b[0].c":        {"a", "b", 0, "c"},
+		"grid[1][12]":     {"grid", 1, 12},
+		"`a[0]`[3].`b.c`": {"a[0]", 3, "b.c"},
+	}
+	for s, expected := range tests {
+		elems, err := parseValuePath(s)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+		if !reflect.DeepEqual(elems, expected) {
+			t.Errorf("%s: expected %#v, got %#v", s, expected, elems)
+		}
+		if formatPath(elems) != s && s != "`a[0]`[3].`b.c`" {
+			t.Errorf("%s: expected it to format as itself, got %s", s, formatPath(elems))
+		}
+	}
+	for _, s := range []string{"", "[0]", "a[", "a[]", "a[-1]", "a[x]", "a[0]b", "a.[0]"} {
+		if elems, err := parseValuePath(s); err == nil {
+			t.Errorf("%s: expected error, got %#v", s, elems)
+		}
+	}
+}
+
+func TestFieldPathString(t *testing.T) {
+	tests := map[string]FieldPath{
+		"address.city":       {"address", "city"},
+		"`a.b`.c":            {"a.b", "c"},
+		"`back\\`tick`":      {"back`tick"},
+		"`1st`.`kebab-case`": {"1st", "kebab-case"},
+	}
+	for expected, path := range tests {
+		if s := path.String(); s != expected {
+			t.Errorf("expected %s, got %s", expected, s)
+		}
+		parsed, err := ParseFieldPath(path.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !parsed.Equal(path) {
+			t.Errorf("round trip: expected %#v, got %#v", path, parsed)
+		}
+	}
+}
+
+func TestChanged(t *testing.T) {
+	e := Event{
+		OldValue: testDoc("alice"),
+		Value:    testDoc("bob"),
+	}
+	e.UpdateMask.FieldPaths = []string{"name", "address.city", "`a.b`"}
+
+	changed := []string{"name", "address.city", "address.city.zip", "`a.b`", "`a.b`.c"}
+	for _, path := range changed {
+		if !e.Changed(path) {
+			t.Errorf("expected %s to be changed", path)
+		}
+	}
+	unchanged := []string{"address", "address.street", "a.b", "a", "email", "not..valid"}
+	for _, path := range unchanged {
+		if e.Changed(path) {
+			t.Errorf("expected %s to be unchanged", path)
+		}
+	}
+
+	if !e.ChangedUnder("address") {
+		t.Errorf("expected a change under address")
+	}
+	if !e.ChangedUnder("address.city.zip") {
+		t.Errorf("expected a change under address.city.zip")
+	}
+	if e.ChangedUnder("email") {
+		t.Errorf("expected no change under email")
+	}
+
+	expected := []FieldPath{{"name"}, {"address", "city"}, {"a.b"}}
+	if fields := e.ChangedFields(); !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected %v, got %v", expected, fields)
+	}
+}
+
+func TestChangedCreate(t *testing.T) {
+	e := Event{Value: testDoc("alice")}
+	if !e.Changed("Name") || !e.Changed("Name.nested") {
+		t.Errorf("expected all fields of a created document to be changed")
+	}
+	if e.Changed("Other") {
+		t.Errorf("expected fields missing from a created document to be unchanged")
+	}
+	expected := []FieldPath{{"Name"}}
+	if fields := e.ChangedFields(); !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected %v, got %v", expected, fields)
+	}
+}
@@ -0,0 +1,368 @@
+package fcf
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tagOptions is the part of a struct tag after the name
+type tagOptions string
+
+// parseTag splits a struct tag into its name and its comma separated options
+func parseTag(tag string) (string, tagOptions) {
+	if i := strings.Index(tag, ","); i != -1 {
+		return tag[:i], tagOptions(tag[i+1:])
+	}
+	return tag, ""
+}
+
+// Contains reports whether the options include name
+func (o tagOptions) Contains(name string) bool {
+	s := string(o)
+	for s != "" {
+		var next string
+		if i := strings.Index(s, ","); i != -1 {
+			s, next = s[:i], s[i+1:]
+		}
+		if s == name {
+			return true
+		}
+		s = next
+	}
+	return false
+}
+
+// DefaultTagNames lists the struct tag keys consulted by Encode and Decode,
+// in order of precedence. The first of these tags present on a field
+// determines its firestore name and options, so by default an fcf tag
+// overrides a firestore tag from the official Go client.
+// It must not be modified concurrently with encoding or decoding.
+var DefaultTagNames = []string{"fcf", "firestore"}
+
+// fieldInfo describes how a struct field maps to a firestore field
+type fieldInfo struct {
+	// key is the firestore field name
+	key string
+	// name is the Go field name
+	name string
+	// index is the field's index sequence for reflect.Value.FieldByIndex,
+	// which is longer than one for fields promoted from embedded structs
+	index     []int
+	typ       reflect.Type
+	tagged    bool
+	omitEmpty bool
+	// serverTimestamp fields are omitted from Encode when zero
+	// so that the server can fill them in
+	serverTimestamp bool
+	// vector fields are slices of floats that Encode stores as vectors
+	vector bool
+}
+
+// structFields describes how a struct type maps to firestore fields
+type structFields struct {
+	list []fieldInfo
+	// remain is the map field collecting the firestore fields
+	// that don't match any field in list, if the struct has one
+	remain *fieldInfo
+}
+
+// keys returns the set of firestore field names that map to struct fields
+func (f structFields) keys() map[string]bool {
+	keys := make(map[string]bool, len(f.list))
+	for _, fieldMeta := range f.list {
+		keys[fieldMeta.key] = true
+	}
+	return keys
+}
+
+type fieldCacheKey struct {
+	typ      reflect.Type
+	tagNames string
+}
+
+var fieldCache sync.Map // map[fieldCacheKey]structFields
+
+// cachedFields returns the fields of struct type t that map to
+// firestore fields, honouring the first of tagNames present on each field:
+//
+//	Field int `fcf:"name"`           // stored as "name"
+//	Field int `fcf:"name,omitempty"` // omitted from Encode when empty
+//	Field int `fcf:",omitempty"`     // stored as "Field", omitted when empty
+//	Field int `fcf:"-"`              // ignored
+//	Field int `fcf:"-,"`             // stored as "-"
+//
+//	Field time.Time `firestore:"t,serverTimestamp"` // omitted from Encode when zero
+//	Extra map[string]interface{} `fcf:",remain"`    // collects all other fields
+//	Embedding []float32 `fcf:"emb,vector"`          // encoded as a vector
+//
+// Only the shallowest map with string keys tagged remain collects the
+// other fields. Any other field tagged remain is stored like a normal field.
+//
+// The fields of embedded structs are promoted into the parent using
+// the same rules as encoding/json, unless the embedded struct is given
+// a name in its tag or the nested option (fcf:",nested"), in which case
+// it is stored as a nested map like any other struct field.
+// Top level fields sharing a key are all decoded from that firestore
+// field, but Encode rejects them unless all but one are omitted.
+func cachedFields(t reflect.Type, tagNames []string) structFields {
+	key := fieldCacheKey{t, strings.Join(tagNames, ",")}
+	if fields, ok := fieldCache.Load(key); ok {
+		return fields.(structFields)
+	}
+	fields, _ := fieldCache.LoadOrStore(key, typeFields(t, tagNames))
+	return fields.(structFields)
+}
+
+// lookupTag returns the first of tagNames present on sf
+func lookupTag(sf reflect.StructField, tagNames []string) string {
+	for _, name := range tagNames {
+		if tag, ok := sf.Tag.Lookup(name); ok {
+			return tag
+		}
+	}
+	return ""
+}
+
+// typeFields walks t breadth first so that shallower fields are found
+// before the embedded fields they hide. It's adapted from encoding/json.
+func typeFields(t reflect.Type, tagNames []string) structFields {
+	// embedded structs to explore at the current and next depth
+	current := []fieldInfo{}
+	next := []fieldInfo{{typ: t}}
+
+	// count of embedded structs of each type at the current and next depth
+	var count, nextCount map[reflect.Type]int
+
+	visited := map[reflect.Type]bool{}
+
+	var fields []fieldInfo
+	var remain *fieldInfo
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, f := range current {
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+
+			for i := 0; i < f.typ.NumField(); i++ {
+				sf := f.typ.Field(i)
+				if sf.Anonymous {
+					t := sf.Type
+					if t.Kind() == reflect.Ptr {
+						t = t.Elem()
+					}
+					if sf.PkgPath != "" && t.Kind() != reflect.Struct {
+						// ignore embedded fields of unexported non-struct types
+						continue
+					}
+					// embedded unexported structs may still have exported fields
+				} else if sf.PkgPath != "" {
+					// unexported fields can't be set
+					continue
+				}
+				tag := lookupTag(sf, tagNames)
+				if tag == "-" {
+					continue
+				}
+				key, opts := parseTag(tag)
+				index := make([]int, len(f.index)+1)
+				copy(index, f.index)
+				index[len(f.index)] = i
+
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+
+				// the shallowest remain field wins, and any other field
+				// tagged remain is treated as a normal field
+				if opts.Contains("remain") && remain == nil &&
+					sf.Type.Kind() == reflect.Map && sf.Type.Key().Kind() == reflect.String {
+					remain = &fieldInfo{name: sf.Name, index: index, typ: sf.Type}
+					continue
+				}
+
+				if key != "" || !sf.Anonymous || ft.Kind() != reflect.Struct || opts.Contains("nested") {
+					if sf.PkgPath != "" {
+						// an unexported embedded struct can't be set
+						// as a whole, only its promoted fields can
+						continue
+					}
+					tagged := key != ""
+					if key == "" {
+						key = sf.Name
+					}
+					fields = append(fields, fieldInfo{
+						key:             key,
+						name:            sf.Name,
+						index:           index,
+						typ:             sf.Type,
+						tagged:          tagged,
+						omitEmpty:       opts.Contains("omitempty"),
+						serverTimestamp: opts.Contains("serverTimestamp"),
+						vector:          opts.Contains("vector"),
+					})
+					if count[f.typ] > 1 {
+						// If there were multiple instances, add a second,
+						// so that the annihilation code will see a duplicate.
+						fields = append(fields, fields[len(fields)-1])
+					}
+					continue
+				}
+
+				// record the embedded struct to explore at the next depth
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, fieldInfo{name: ft.Name(), index: index, typ: ft})
+				}
+			}
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		x := fields
+		// sort by key, breaking ties with depth, then
+		// breaking ties with "key came from fcf tag", then
+		// breaking ties with index sequence
+		if x[i].key != x[j].key {
+			return x[i].key < x[j].key
+		}
+		if len(x[i].index) != len(x[j].index) {
+			return len(x[i].index) < len(x[j].index)
+		}
+		if x[i].tagged != x[j].tagged {
+			return x[i].tagged
+		}
+		return indexLess(x[i].index, x[j].index)
+	})
+
+	// Delete all fields that are hidden by the Go rules for embedded fields,
+	// except that fields with fcf tags are promoted.
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		fi := fields[i]
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].key != fi.key {
+				break
+			}
+		}
+		if advance == 1 {
+			out = append(out, fi)
+			continue
+		}
+		if len(fi.index) == 1 {
+			// Top level fields hide embedded ones. Unlike encoding/json,
+			// several top level fields may share a key, in which case
+			// they are all decoded from the same firestore field,
+			// and Encode fails unless all but one are omitted.
+			for _, f := range fields[i : i+advance] {
+				if len(f.index) == 1 {
+					out = append(out, f)
+				}
+			}
+			continue
+		}
+		if dominant, ok := dominantField(fields[i : i+advance]); ok {
+			out = append(out, dominant)
+		}
+	}
+	fields = out
+	sort.Slice(fields, func(i, j int) bool {
+		return indexLess(fields[i].index, fields[j].index)
+	})
+	return structFields{list: fields, remain: remain}
+}
+
+func indexLess(x, y []int) bool {
+	for k, xik := range x {
+		if k >= len(y) {
+			return false
+		}
+		if xik != y[k] {
+			return xik < y[k]
+		}
+	}
+	return len(x) < len(y)
+}
+
+// dominantField looks through the embedded fields, all of which are known
+// to have the same key, to find the single field that dominates the
+// others using Go's embedding rules, modified by the presence of
+// fcf tags. If there are multiple shallowest fields, the boolean
+// will be false: This condition is an error in Go and we skip all
+// the fields.
+func dominantField(fields []fieldInfo) (fieldInfo, bool) {
+	// The fields are sorted in increasing index-length order, then by presence of tag.
+	// That means that the first field is the dominant one. We need only check
+	// for error cases: two fields at the same depth, either both tagged or neither tagged.
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) && fields[0].tagged == fields[1].tagged {
+		return fieldInfo{}, false
+	}
+	return fields[0], true
+}
+
+// fieldByIndex returns the field of struct v at index,
+// allocating any nil embedded struct pointers along the way
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("Cannot set embedded pointer to unexported struct: %v", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+// existingFieldByIndex returns the field of struct v at index,
+// or false if it is within a nil embedded struct pointer
+func existingFieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// isEmptyValue reports whether v is empty for the purposes of omitempty
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	case reflect.Struct:
+		switch v.Type() {
+		case timeType:
+			return v.Interface().(time.Time).IsZero()
+		case documentRefType:
+			return v.Interface().(DocumentRef) == DocumentRef{}
+		}
+	}
+	return false
+}
@@ -0,0 +1,474 @@
+package fcf
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTag(t *testing.T) {
+	name, opts := parseTag("name,omitempty,other")
+	if name != "name" {
+		t.Errorf("expected %q, got %q", "name", name)
+	}
+	if !opts.Contains("omitempty") || !opts.Contains("other") {
+		t.Errorf("expected options to contain omitempty and other, got %q", opts)
+	}
+	if opts.Contains("omit") || opts.Contains("") {
+		t.Errorf("expected options not to contain partial matches, got %q", opts)
+	}
+	name, opts = parseTag("name")
+	if name != "name" || opts != "" {
+		t.Errorf("expected name with no options, got %q %q", name, opts)
+	}
+}
+
+func TestTagOptions(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"-":         map[string]interface{}{"stringValue": "dash"},
+			"Skipped":   map[string]interface{}{"stringValue": "skipped"},
+			"Fallback":  map[string]interface{}{"stringValue": "fallback"},
+			"otherName": map[string]interface{}{"stringValue": "other"},
+		},
+	}
+
+	userVal := &struct {
+		Skipped  string `fcf:"-"`
+		Dash     string `fcf:"-,"`
+		Fallback string `fcf:",omitempty"`
+		Named    string `fcf:"otherName,omitempty"`
+	}{}
+	if err := fcfVal.Decode(userVal); err != nil {
+		t.Fatal(err)
+	}
+	if userVal.Skipped != "" {
+		t.Errorf("expected skipped field to be empty, got %q", userVal.Skipped)
+	}
+	if userVal.Dash != "dash" {
+		t.Errorf("expected %q, got %q", "dash", userVal.Dash)
+	}
+	if userVal.Fallback != "fallback" {
+		t.Errorf("expected %q, got %q", "fallback", userVal.Fallback)
+	}
+	if userVal.Named != "other" {
+		t.Errorf("expected %q, got %q", "other", userVal.Named)
+	}
+}
+
+func TestEncodeOmitEmpty(t *testing.T) {
+	fields, err := Encode(struct {
+		Skipped string            `fcf:"-"`
+		String  string            `fcf:",omitempty"`
+		Int     int               `fcf:"int,omitempty"`
+		Ptr     *int              `fcf:",omitempty"`
+		Slice   []string          `fcf:",omitempty"`
+		Map     map[string]string `fcf:",omitempty"`
+		Time    time.Time         `fcf:",omitempty"`
+		Kept    string            `fcf:"kept,omitempty"`
+		Zero    int
+	}{
+		Skipped: "skipped",
+		Slice:   []string{},
+		Kept:    "kept",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"kept": map[string]interface{}{"stringValue": "kept"},
+		"Zero": map[string]interface{}{"integerValue": "0"},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected %v, got %v", expected, fields)
+	}
+}
+
+type Audit struct {
+	CreatedBy string
+	UpdatedBy string
+}
+
+type Timestamps struct {
+	CreatedAt string
+	UpdatedBy string
+}
+
+type tagged struct {
+	Tagged string `fcf:"UpdatedBy"`
+}
+
+func TestEmbedded(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"Name":      map[string]interface{}{"stringValue": "alice"},
+			"CreatedBy": map[string]interface{}{"stringValue": "bob"},
+			"UpdatedBy": map[string]interface{}{"stringValue": "carol"},
+			"CreatedAt": map[string]interface{}{"stringValue": "yesterday"},
+		},
+	}
+
+	userVal := &struct {
+		Audit
+		*Timestamps
+		Name string
+	}{}
+	if err := fcfVal.Decode(userVal); err != nil {
+		t.Fatal(err)
+	}
+	if userVal.Name != "alice" || userVal.CreatedBy != "bob" {
+		t.Errorf("expected promoted fields to be decoded, got %+v", userVal)
+	}
+	if userVal.Timestamps == nil || userVal.CreatedAt != "yesterday" {
+		t.Fatalf("expected embedded pointer to be allocated and decoded, got %+v", userVal.Timestamps)
+	}
+	// UpdatedBy is ambiguous between Audit and Timestamps
+	if userVal.Audit.UpdatedBy != "" || userVal.Timestamps.UpdatedBy != "" {
+		t.Errorf("expected ambiguous fields to be ignored, got %+v", userVal)
+	}
+}
+
+func TestEmbeddedPrecedence(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"CreatedBy": map[string]interface{}{"stringValue": "bob"},
+			"UpdatedBy": map[string]interface{}{"stringValue": "carol"},
+		},
+	}
+
+	userVal := &struct {
+		Audit
+		tagged
+		CreatedBy int `fcf:"-"`
+	}{}
+	if err := fcfVal.Decode(userVal); err != nil {
+		t.Fatal(err)
+	}
+	if userVal.Tagged != "carol" {
+		t.Errorf("expected tagged field to win, got %q", userVal.Tagged)
+	}
+	if userVal.Audit.UpdatedBy != "" {
+		t.Errorf("expected untagged field to be hidden, got %q", userVal.Audit.UpdatedBy)
+	}
+	if userVal.Audit.CreatedBy != "bob" {
+		t.Errorf("expected %q, got %q", "bob", userVal.Audit.CreatedBy)
+	}
+
+	shadowed := &struct {
+		Audit
+		CreatedBy string
+	}{}
+	if err := fcfVal.Decode(shadowed); err != nil {
+		t.Fatal(err)
+	}
+	if shadowed.CreatedBy != "bob" || shadowed.Audit.CreatedBy != "" {
+		t.Errorf("expected top level field to hide embedded field, got %+v", shadowed)
+	}
+}
+
+func TestEmbeddedNested(t *testing.T) {
+	audit := Audit{CreatedBy: "bob", UpdatedBy: "carol"}
+	nested := map[string]interface{}{"mapValue": map[string]interface{}{
+		"fields": map[string]interface{}{
+			"CreatedBy": map[string]interface{}{"stringValue": audit.CreatedBy},
+			"UpdatedBy": map[string]interface{}{"stringValue": audit.UpdatedBy},
+		},
+	}}
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"Audit": nested,
+			"audit": nested,
+		},
+	}
+
+	userVal := &struct {
+		Audit `fcf:",nested"`
+	}{}
+	if err := fcfVal.Decode(userVal); err != nil {
+		t.Fatal(err)
+	}
+	if userVal.Audit != audit {
+		t.Errorf("expected %+v, got %+v", audit, userVal.Audit)
+	}
+
+	named := &struct {
+		Audit `fcf:"audit"`
+	}{}
+	if err := fcfVal.Decode(named); err != nil {
+		t.Fatal(err)
+	}
+	if named.Audit != audit {
+		t.Errorf("expected %+v, got %+v", audit, named.Audit)
+	}
+}
+
+func TestEmbeddedUnexportedNested(t *testing.T) {
+	nested := map[string]interface{}{"mapValue": map[string]interface{}{
+		"fields": map[string]interface{}{
+			"Tagged": map[string]interface{}{"stringValue": "inner"},
+		},
+	}}
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"in":     nested,
+			"tagged": nested,
+		},
+	}
+
+	named := &struct {
+		tagged `fcf:"in"`
+	}{}
+	if err := fcfVal.Decode(named); err != nil {
+		t.Fatal(err)
+	}
+	if named.Tagged != "" {
+		t.Errorf("expected unexported embedded struct to be ignored, got %q", named.Tagged)
+	}
+
+	nestedOpt := &struct {
+		tagged `fcf:",nested"`
+	}{}
+	if err := fcfVal.Decode(nestedOpt); err != nil {
+		t.Fatal(err)
+	}
+	fields, err := Encode(nestedOpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 0 {
+		t.Errorf("expected no fields, got %v", fields)
+	}
+}
+
+func TestDuplicateKeys(t *testing.T) {
+	type doc struct {
+		A int `fcf:"a"`
+		B int `fcf:"a,omitempty"`
+	}
+	fcfVal := Value{Fields: map[string]interface{}{
+		"a": map[string]interface{}{"integerValue": "1"},
+	}}
+	var userVal doc
+	if err := fcfVal.Decode(&userVal); err != nil {
+		t.Fatal(err)
+	}
+	if userVal != (doc{A: 1, B: 1}) {
+		t.Errorf("expected both fields to be decoded, got %+v", userVal)
+	}
+
+	// encoding can't choose between A and B, so it fails
+	if _, err := Encode(userVal); err == nil {
+		t.Errorf("expected error encoding two fields with the same key")
+	}
+	// unless all but one are omitted
+	fields, err := Encode(doc{A: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fields, fcfVal.Fields) {
+		t.Errorf("expected %v, got %v", fcfVal.Fields, fields)
+	}
+}
+
+func TestEncodeEmbedded(t *testing.T) {
+	type doc struct {
+		Audit
+		*Timestamps
+		Name string
+	}
+	fields, err := Encode(doc{Audit: Audit{CreatedBy: "bob"}, Name: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"Name":      map[string]interface{}{"stringValue": "alice"},
+		"CreatedBy": map[string]interface{}{"stringValue": "bob"},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected %v, got %v", expected, fields)
+	}
+
+	testVal := doc{Audit: Audit{CreatedBy: "bob"}, Timestamps: &Timestamps{CreatedAt: "now"}, Name: "alice"}
+	fcfVal, err := NewValue(testVal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var userVal doc
+	if err := fcfVal.Decode(&userVal); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(testVal, userVal) {
+		t.Errorf("round trip mismatch: expected %+v, got %+v", testVal, userVal)
+	}
+}
+
+func TestFirestoreTags(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"name":  map[string]interface{}{"stringValue": "alice"},
+			"fcf":   map[string]interface{}{"stringValue": "fcf"},
+			"store": map[string]interface{}{"stringValue": "firestore"},
+		},
+	}
+
+	type doc struct {
+		Name    string `firestore:"name,omitempty"`
+		Both    string `fcf:"fcf" firestore:"store"`
+		Ignored string `firestore:"-"`
+	}
+	var userVal doc
+	if err := fcfVal.Decode(&userVal); err != nil {
+		t.Fatal(err)
+	}
+	expected := doc{Name: "alice", Both: "fcf"}
+	if userVal != expected {
+		t.Errorf("expected %+v, got %+v", expected, userVal)
+	}
+
+	defer func(tagNames []string) { DefaultTagNames = tagNames }(DefaultTagNames)
+	DefaultTagNames = []string{"firestore", "fcf"}
+	userVal = doc{}
+	if err := fcfVal.Decode(&userVal); err != nil {
+		t.Fatal(err)
+	}
+	expected = doc{Name: "alice", Both: "firestore"}
+	if userVal != expected {
+		t.Errorf("expected %+v, got %+v", expected, userVal)
+	}
+}
+
+func TestEncodeServerTimestamp(t *testing.T) {
+	type doc struct {
+		Name    string    `firestore:"name,omitempty"`
+		Created time.Time `firestore:"created,serverTimestamp"`
+	}
+	fields, err := Encode(doc{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 0 {
+		t.Errorf("expected empty fields to be omitted, got %v", fields)
+	}
+
+	created := time.Date(2019, time.February, 3, 1, 7, 5, 0, time.UTC)
+	fields, err = Encode(doc{Created: created})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"created": map[string]interface{}{"timestampValue": "2019-02-03T01:07:05Z"},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected %v, got %v", expected, fields)
+	}
+}
+
+func TestRemain(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"name":  map[string]interface{}{"stringValue": "alice"},
+			"age":   map[string]interface{}{"integerValue": "42"},
+			"admin": map[string]interface{}{"booleanValue": true},
+			"address": map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{
+				"city": map[string]interface{}{"stringValue": "Paris"},
+			}}},
+		},
+	}
+
+	type doc struct {
+		Name  string                 `fcf:"name"`
+		Extra map[string]interface{} `fcf:",remain"`
+	}
+	var userVal doc
+	if err := NewDecoder(DisallowUnknownFields()).Decode(fcfVal, &userVal); err != nil {
+		t.Fatal(err)
+	}
+	expected := doc{
+		Name: "alice",
+		Extra: map[string]interface{}{
+			"age":     42,
+			"admin":   true,
+			"address": map[string]interface{}{"city": "Paris"},
+		},
+	}
+	if !reflect.DeepEqual(userVal, expected) {
+		t.Errorf("expected %+v, got %+v", expected, userVal)
+	}
+
+	fields, err := Encode(userVal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fields, fcfVal.Fields) {
+		t.Errorf("expected %v, got %v", fcfVal.Fields, fields)
+	}
+}
+
+func TestRemainTyped(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"name":  map[string]interface{}{"stringValue": "alice"},
+			"title": map[string]interface{}{"stringValue": "admin"},
+		},
+	}
+	var userVal struct {
+		Name  string            `fcf:"name"`
+		Extra map[string]string `fcf:",remain"`
+	}
+	if err := fcfVal.Decode(&userVal); err != nil {
+		t.Fatal(err)
+	}
+	if userVal.Name != "alice" || !reflect.DeepEqual(userVal.Extra, map[string]string{"title": "admin"}) {
+		t.Errorf("unexpected result %+v", userVal)
+	}
+
+	// struct fields win over remain entries with the same key
+	userVal.Extra["name"] = "bob"
+	fields, err := Encode(userVal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fields, fcfVal.Fields) {
+		t.Errorf("expected %v, got %v", fcfVal.Fields, fields)
+	}
+}
+
+func TestRemainInvalid(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"Note": map[string]interface{}{"stringValue": "hello"},
+			"Second": map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{
+				"a": map[string]interface{}{"stringValue": "b"},
+			}}},
+			"title": map[string]interface{}{"stringValue": "admin"},
+		},
+	}
+
+	// only the first remain map collects the other fields,
+	// the rest are stored like any other field
+	type doc struct {
+		Note   string                 `fcf:",remain"`
+		Extra  map[string]interface{} `fcf:",remain"`
+		Second map[string]string      `fcf:",remain"`
+	}
+	var userVal doc
+	if err := NewDecoder(DisallowUnknownFields()).Decode(fcfVal, &userVal); err != nil {
+		t.Fatal(err)
+	}
+	expected := doc{
+		Note:   "hello",
+		Extra:  map[string]interface{}{"title": "admin"},
+		Second: map[string]string{"a": "b"},
+	}
+	if !reflect.DeepEqual(userVal, expected) {
+		t.Errorf("expected %+v, got %+v", expected, userVal)
+	}
+
+	fields, err := Encode(userVal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fields, fcfVal.Fields) {
+		t.Errorf("expected %v, got %v", fcfVal.Fields, fields)
+	}
+}
@@ -0,0 +1,150 @@
+package fcf
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var (
+	// ErrNoField is wrapped by the errors Value.Get returns
+	// when there is no value at the path
+	ErrNoField = errors.New("no such field")
+	// ErrWrongType is wrapped by the errors Value.Get and its typed
+	// variants return when a value along the path has an unexpected type
+	ErrWrongType = errors.New("wrong type")
+)
+
+// Get returns the value at path within the document's fields.
+// path is a field path whose field names may be followed by array
+// indexes, e.g. address.lines[0].text, the syntax of Change.Path.
+// The returned error wraps ErrNoField if there is no value at path,
+// or ErrWrongType if path indexes into a value that isn't a map or array.
+func (v Value) Get(path string) (Node, error) {
+	elems, err := parseValuePath(path)
+	if err != nil {
+		return Node{}, err
+	}
+	fcfVal, fcfType := reflect.ValueOf(map[string]interface{}{"fields": v.Fields}), "mapValue"
+	for i, elem := range elems {
+		parent := formatPath(elems[:i])
+		if parent == "" {
+			parent = "document"
+		}
+		wantType, wantKind := "mapValue", MapNode
+		if _, ok := elem.(int); ok {
+			wantType, wantKind = "arrayValue", ArrayNode
+		}
+		if fcfType != wantType {
+			n, err := parseNode(fcfType, fcfVal)
+			if err != nil {
+				return Node{}, fmt.Errorf("Cannot get %s: %v", parent, err)
+			}
+			return Node{}, fmt.Errorf("%w: %s is %v, not %v", ErrWrongType, parent, n.Kind(), wantKind)
+		}
+		container, err := getContainer(fcfVal, fcfType)
+		if err != nil {
+			return Node{}, fmt.Errorf("Cannot get %s: %v", parent, err)
+		}
+
+		var wrapped reflect.Value
+		switch elem := elem.(type) {
+		case string:
+			wrapped = container.MapIndex(reflect.ValueOf(elem))
+		case int:
+			if elem < container.Len() {
+				wrapped = container.Index(elem)
+			}
+		}
+		if !wrapped.IsValid() {
+			return Node{}, fmt.Errorf("%w: %s", ErrNoField, formatPath(elems[:i+1]))
+		}
+		if fcfVal, fcfType, err = unwrapFcfVal(wrapped); err != nil {
+			return Node{}, fmt.Errorf("Cannot get %s: %v", formatPath(elems[:i+1]), err)
+		}
+	}
+	n, err := parseNode(fcfType, fcfVal)
+	if err != nil {
+		return Node{}, fmt.Errorf("Cannot get %s: %v", path, err)
+	}
+	return n, nil
+}
+
+// getKind returns the value at path, which must be of the given kind
+func (v Value) getKind(path string, kind NodeKind) (Node, error) {
+	n, err := v.Get(path)
+	if err != nil {
+		return Node{}, err
+	}
+	if n.Kind() != kind {
+		return Node{}, fmt.Errorf("%w: %s is %v, not %v", ErrWrongType, path, n.Kind(), kind)
+	}
+	return n, nil
+}
+
+// GetString returns the stringValue at path. See Get for the path syntax.
+func (v Value) GetString(path string) (string, error) {
+	n, err := v.getKind(path, StringNode)
+	s, _ := n.AsString()
+	return s, err
+}
+
+// GetBool returns the booleanValue at path
+func (v Value) GetBool(path string) (bool, error) {
+	n, err := v.getKind(path, BooleanNode)
+	b, _ := n.AsBool()
+	return b, err
+}
+
+// GetInt64 returns the integerValue at path
+func (v Value) GetInt64(path string) (int64, error) {
+	n, err := v.getKind(path, IntegerNode)
+	i, _ := n.AsInt()
+	return i, err
+}
+
+// GetFloat64 returns the doubleValue or integerValue at path.
+// Integers are accepted because clients such as the JavaScript SDK
+// store whole numbers as integerValues.
+func (v Value) GetFloat64(path string) (float64, error) {
+	n, err := v.Get(path)
+	if err != nil {
+		return 0, err
+	}
+	if i, ok := n.AsInt(); ok {
+		return float64(i), nil
+	}
+	if f, ok := n.AsDouble(); ok {
+		return f, nil
+	}
+	return 0, fmt.Errorf("%w: %s is %v, not %v", ErrWrongType, path, n.Kind(), DoubleNode)
+}
+
+// GetTime returns the timestampValue at path
+func (v Value) GetTime(path string) (time.Time, error) {
+	n, err := v.getKind(path, TimestampNode)
+	t, _ := n.AsTime()
+	return t, err
+}
+
+// GetBytes returns the bytesValue at path
+func (v Value) GetBytes(path string) ([]byte, error) {
+	n, err := v.getKind(path, BytesNode)
+	b, _ := n.AsBytes()
+	return b, err
+}
+
+// GetRef returns the referenceValue at path
+func (v Value) GetRef(path string) (DocumentRef, error) {
+	n, err := v.getKind(path, ReferenceNode)
+	ref, _ := n.AsRef()
+	return ref, err
+}
+
+// GetGeoPoint returns the geoPointValue at path
+func (v Value) GetGeoPoint(path string) (GeoPoint, error) {
+	n, err := v.getKind(path, GeoPointNode)
+	p, _ := n.AsGeoPoint()
+	return p, err
+}
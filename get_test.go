@@ -0,0 +1,103 @@
+package fcf
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func getTestValue() Value {
+	return Value{
+		Fields: map[string]interface{}{
+			"name":   map[string]interface{}{"stringValue": "alice"},
+			"age":    map[string]interface{}{"integerValue": "42"},
+			"admin":  map[string]interface{}{"booleanValue": true},
+			"joined": map[string]interface{}{"timestampValue": "2019-02-03T01:07:05Z"},
+			"avatar": map[string]interface{}{"bytesValue": "Zm9v"},
+			"boss":   map[string]interface{}{"referenceValue": testDocName},
+			"home":   map[string]interface{}{"geoPointValue": map[string]interface{}{"latitude": 1.5, "longitude": 2.5}},
+			"score":  map[string]interface{}{"doubleValue": 9.5},
+			"address": map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{
+				"lines": map[string]interface{}{"arrayValue": map[string]interface{}{"values": []interface{}{
+					map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{
+						"text": map[string]interface{}{"stringValue": "1 Main St"},
+					}}},
+				}}},
+				"zip code": map[string]interface{}{"stringValue": "12345"},
+			}}},
+		},
+	}
+}
+
+func TestGet(t *testing.T) {
+	v := getTestValue()
+
+	n, err := v.Get("address.lines[0]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Kind() != MapNode {
+		t.Errorf("expected a map, got %v", n.Kind())
+	}
+
+	if s, err := v.GetString("address.lines[0].text"); err != nil || s != "1 Main St" {
+		t.Errorf("expected %q, got %q, %v", "1 Main St", s, err)
+	}
+	if s, err := v.GetString("address.`zip code`"); err != nil || s != "12345" {
+		t.Errorf("expected %q, got %q, %v", "12345", s, err)
+	}
+	if i, err := v.GetInt64("age"); err != nil || i != 42 {
+		t.Errorf("expected 42, got %v, %v", i, err)
+	}
+	if f, err := v.GetFloat64("age"); err != nil || f != 42 {
+		t.Errorf("expected 42, got %v, %v", f, err)
+	}
+	if f, err := v.GetFloat64("score"); err != nil || f != 9.5 {
+		t.Errorf("expected 9.5, got %v, %v", f, err)
+	}
+	if b, err := v.GetBool("admin"); err != nil || !b {
+		t.Errorf("expected true, got %v, %v", b, err)
+	}
+	expectedTime := time.Date(2019, time.February, 3, 1, 7, 5, 0, time.UTC)
+	if tm, err := v.GetTime("joined"); err != nil || !tm.Equal(expectedTime) {
+		t.Errorf("expected %v, got %v, %v", expectedTime, tm, err)
+	}
+	if b, err := v.GetBytes("avatar"); err != nil || string(b) != "foo" {
+		t.Errorf("expected foo, got %q, %v", b, err)
+	}
+	if ref, err := v.GetRef("boss"); err != nil || ref.Name() != testDocName {
+		t.Errorf("expected %s, got %v, %v", testDocName, ref, err)
+	}
+	if p, err := v.GetGeoPoint("home"); err != nil || p != (GeoPoint{1.5, 2.5}) {
+		t.Errorf("expected {1.5 2.5}, got %v, %v", p, err)
+	}
+}
+
+func TestGetErrors(t *testing.T) {
+	v := getTestValue()
+	tests := []struct {
+		path     string
+		get      func(string) error
+		expected error
+	}{
+		{"missing", func(p string) error { _, err := v.Get(p); return err }, ErrNoField},
+		{"address.missing", func(p string) error { _, err := v.Get(p); return err }, ErrNoField},
+		{"address.lines[1]", func(p string) error { _, err := v.Get(p); return err }, ErrNoField},
+		{"address.lines.text", func(p string) error { _, err := v.Get(p); return err }, ErrWrongType},
+		{"name[0]", func(p string) error { _, err := v.Get(p); return err }, ErrWrongType},
+		{"name.first", func(p string) error { _, err := v.Get(p); return err }, ErrWrongType},
+		{"age", func(p string) error { _, err := v.GetString(p); return err }, ErrWrongType},
+		{"name", func(p string) error { _, err := v.GetInt64(p); return err }, ErrWrongType},
+		{"name", func(p string) error { _, err := v.GetFloat64(p); return err }, ErrWrongType},
+		{"missing", func(p string) error { _, err := v.GetTime(p); return err }, ErrNoField},
+	}
+	for _, test := range tests {
+		err := test.get(test.path)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.path, test.expected, err)
+		}
+	}
+	if _, err := v.Get("a[x]"); err == nil || errors.Is(err, ErrNoField) {
+		t.Errorf("expected a syntax error, got %v", err)
+	}
+}
@@ -0,0 +1,402 @@
+package fcf
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// NodeKind is the Firestore type of a Node
+type NodeKind int
+
+const (
+	// NullNode is a nullValue. It is the kind of the zero Node.
+	NullNode NodeKind = iota
+	// BooleanNode is a booleanValue
+	BooleanNode
+	// IntegerNode is an integerValue
+	IntegerNode
+	// DoubleNode is a doubleValue
+	DoubleNode
+	// TimestampNode is a timestampValue
+	TimestampNode
+	// StringNode is a stringValue
+	StringNode
+	// BytesNode is a bytesValue
+	BytesNode
+	// ReferenceNode is a referenceValue
+	ReferenceNode
+	// GeoPointNode is a geoPointValue
+	GeoPointNode
+	// ArrayNode is an arrayValue
+	ArrayNode
+	// MapNode is a mapValue
+	MapNode
+	// VectorNode is a vector, which Firestore stores as a special mapValue
+	VectorNode
+)
+
+func (k NodeKind) String() string {
+	switch k {
+	case NullNode:
+		return "null"
+	case BooleanNode:
+		return "boolean"
+	case IntegerNode:
+		return "integer"
+	case DoubleNode:
+		return "double"
+	case TimestampNode:
+		return "timestamp"
+	case StringNode:
+		return "string"
+	case BytesNode:
+		return "bytes"
+	case ReferenceNode:
+		return "reference"
+	case GeoPointNode:
+		return "geoPoint"
+	case ArrayNode:
+		return "array"
+	case MapNode:
+		return "map"
+	case VectorNode:
+		return "vector"
+	}
+	return "unknown"
+}
+
+// Node is a Firestore value that keeps its type, unlike values decoded
+// into interface{} fields. Decode a document or any of its fields into
+// a Node to inspect it without knowing its schema, and Encode a Node
+// to get back the value it was decoded from. Only a MapNode can be
+// encoded as a whole document. The zero Node is null.
+type Node struct {
+	kind NodeKind
+	// val is a bool, int64, float64, time.Time, string, []byte,
+	// DocumentRef, GeoPoint, []Node, map[string]Node or Vector
+	// depending on kind
+	val interface{}
+}
+
+var nodeType = reflect.TypeOf(Node{})
+
+// Kind returns the Firestore type of the node
+func (n Node) Kind() NodeKind {
+	return n.kind
+}
+
+// IsNull reports whether the node is a nullValue
+func (n Node) IsNull() bool {
+	return n.kind == NullNode
+}
+
+// AsBool returns the value of a BooleanNode.
+// The second return value reports whether the node is one.
+func (n Node) AsBool() (bool, bool) {
+	b, ok := n.val.(bool)
+	return b, ok && n.kind == BooleanNode
+}
+
+// AsInt returns the value of an IntegerNode.
+// The second return value reports whether the node is one.
+func (n Node) AsInt() (int64, bool) {
+	i, ok := n.val.(int64)
+	return i, ok && n.kind == IntegerNode
+}
+
+// AsDouble returns the value of a DoubleNode.
+// The second return value reports whether the node is one.
+func (n Node) AsDouble() (float64, bool) {
+	f, ok := n.val.(float64)
+	return f, ok && n.kind == DoubleNode
+}
+
+// AsTime returns the value of a TimestampNode.
+// The second return value reports whether the node is one.
+func (n Node) AsTime() (time.Time, bool) {
+	t, ok := n.val.(time.Time)
+	return t, ok && n.kind == TimestampNode
+}
+
+// AsString returns the value of a StringNode.
+// The second return value reports whether the node is one.
+func (n Node) AsString() (string, bool) {
+	s, ok := n.val.(string)
+	return s, ok && n.kind == StringNode
+}
+
+// AsBytes returns the value of a BytesNode.
+// The second return value reports whether the node is one.
+func (n Node) AsBytes() ([]byte, bool) {
+	b, ok := n.val.([]byte)
+	return b, ok && n.kind == BytesNode
+}
+
+// AsRef returns the value of a ReferenceNode.
+// The second return value reports whether the node is one.
+func (n Node) AsRef() (DocumentRef, bool) {
+	ref, ok := n.val.(DocumentRef)
+	return ref, ok && n.kind == ReferenceNode
+}
+
+// AsGeoPoint returns the value of a GeoPointNode.
+// The second return value reports whether the node is one.
+func (n Node) AsGeoPoint() (GeoPoint, bool) {
+	p, ok := n.val.(GeoPoint)
+	return p, ok && n.kind == GeoPointNode
+}
+
+// AsVector returns the value of a VectorNode.
+// The second return value reports whether the node is one.
+func (n Node) AsVector() (Vector, bool) {
+	v, ok := n.val.(Vector)
+	return v, ok && n.kind == VectorNode
+}
+
+// Len returns the number of elements of an ArrayNode or VectorNode,
+// the number of fields of a MapNode, and zero for any other node
+func (n Node) Len() int {
+	switch val := n.val.(type) {
+	case []Node:
+		return len(val)
+	case map[string]Node:
+		return len(val)
+	case Vector:
+		return len(val)
+	}
+	return 0
+}
+
+// Elems returns the elements of an ArrayNode, or nil for any other node.
+// The slice must not be modified.
+func (n Node) Elems() []Node {
+	elems, _ := n.val.([]Node)
+	return elems
+}
+
+// Index returns the i'th element of an ArrayNode.
+// It returns a null node if n isn't an array or i is out of range.
+func (n Node) Index(i int) Node {
+	elems := n.Elems()
+	if i < 0 || i >= len(elems) {
+		return Node{}
+	}
+	return elems[i]
+}
+
+// Keys returns the sorted field names of a MapNode, or nil for any other node
+func (n Node) Keys() []string {
+	fields, ok := n.val.(map[string]Node)
+	if !ok {
+		return nil
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Field returns the field of a MapNode with the given name.
+// The second return value reports whether the field exists.
+func (n Node) Field(key string) (Node, bool) {
+	fields, _ := n.val.(map[string]Node)
+	field, ok := fields[key]
+	return field, ok
+}
+
+// Interface returns the node's value as a plain Go value:
+// nil, bool, int64, float64, time.Time, string, []byte, DocumentRef,
+// GeoPoint, Vector, []interface{} or map[string]interface{}
+func (n Node) Interface() interface{} {
+	switch val := n.val.(type) {
+	case []Node:
+		elems := make([]interface{}, len(val))
+		for i, elem := range val {
+			elems[i] = elem.Interface()
+		}
+		return elems
+	case map[string]Node:
+		fields := make(map[string]interface{}, len(val))
+		for key, field := range val {
+			fields[key] = field.Interface()
+		}
+		return fields
+	}
+	return n.val
+}
+
+// UnmarshalFirestore implements Unmarshaler, so that Decode can decode
+// any firestore value into a Node
+func (n *Node) UnmarshalFirestore(fcfType string, raw interface{}) error {
+	node, err := parseNode(fcfType, reflect.ValueOf(raw))
+	if err != nil {
+		return err
+	}
+	*n = node
+	return nil
+}
+
+// parseNode converts an unwrapped firestore value into a Node
+func parseNode(fcfType string, fcfVal reflect.Value) (Node, error) {
+	switch fcfType {
+	case "nullValue":
+		return Node{}, nil
+	case "booleanValue":
+		if fcfVal.Kind() != reflect.Bool {
+			return Node{}, fmt.Errorf("expected a bool, got %v", fcfVal.Kind())
+		}
+		return Node{BooleanNode, fcfVal.Bool()}, nil
+	case "integerValue":
+		s, err := convInteger(fcfVal)
+		if err != nil {
+			return Node{}, err
+		}
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return Node{}, err
+		}
+		return Node{IntegerNode, i}, nil
+	case "doubleValue":
+		f, err := convDouble(fcfVal)
+		if err != nil {
+			return Node{}, err
+		}
+		return Node{DoubleNode, f}, nil
+	case "timestampValue":
+		t, err := convTimestamp(fcfVal)
+		if err != nil {
+			return Node{}, err
+		}
+		return Node{TimestampNode, t.Interface()}, nil
+	case "stringValue":
+		s, err := convString(fcfVal)
+		if err != nil {
+			return Node{}, err
+		}
+		return Node{StringNode, s}, nil
+	case "bytesValue":
+		b, err := convBytes(fcfVal)
+		if err != nil {
+			return Node{}, err
+		}
+		return Node{BytesNode, b.Interface()}, nil
+	case "referenceValue":
+		ref, err := convReference(fcfVal, documentRefType)
+		if err != nil {
+			return Node{}, err
+		}
+		return Node{ReferenceNode, ref.Interface()}, nil
+	case "geoPointValue":
+		return parseGeoPointNode(fcfVal)
+	case "arrayValue":
+		values, err := getContainer(fcfVal, fcfType)
+		if err != nil {
+			return Node{}, err
+		}
+		elems := make([]Node, values.Len())
+		for i := range elems {
+			if elems[i], err = parseWrappedNode(values.Index(i)); err != nil {
+				return Node{}, fmt.Errorf("[%d]: %v", i, err)
+			}
+		}
+		return Node{ArrayNode, elems}, nil
+	case "mapValue":
+		fields, err := getContainer(fcfVal, fcfType)
+		if err != nil {
+			return Node{}, err
+		}
+		if isVector(fields) {
+			elems, err := vectorElems(fields)
+			if err != nil {
+				return Node{}, err
+			}
+			return Node{VectorNode, Vector(elems)}, nil
+		}
+		nodes := make(map[string]Node, fields.Len())
+		for _, key := range fields.MapKeys() {
+			if nodes[key.String()], err = parseWrappedNode(fields.MapIndex(key)); err != nil {
+				return Node{}, fmt.Errorf("%s: %v", quoteFieldName(key.String()), err)
+			}
+		}
+		return Node{MapNode, nodes}, nil
+	}
+	return Node{}, fmt.Errorf("unknown firestore type %q", fcfType)
+}
+
+func parseWrappedNode(wrapped reflect.Value) (Node, error) {
+	fcfVal, fcfType, err := unwrapFcfVal(wrapped)
+	if err != nil {
+		return Node{}, err
+	}
+	return parseNode(fcfType, fcfVal)
+}
+
+func parseGeoPointNode(fcfVal reflect.Value) (Node, error) {
+	if fcfVal.Kind() != reflect.Map {
+		return Node{}, fmt.Errorf("malformed firestore geoPointValue")
+	}
+	var coords [2]float64
+	for i, key := range []string{"latitude", "longitude"} {
+		// protojson omits zero coordinates
+		coord := fcfVal.MapIndex(reflect.ValueOf(key))
+		if !coord.IsValid() {
+			continue
+		}
+		f, err := convDouble(coord.Elem())
+		if err != nil {
+			return Node{}, fmt.Errorf("%s: %v", key, err)
+		}
+		coords[i] = f
+	}
+	return Node{GeoPointNode, GeoPoint{Latitude: coords[0], Longitude: coords[1]}}, nil
+}
+
+// encodeNode returns the wire format of a Node
+func encodeNode(n Node) interface{} {
+	switch val := n.val.(type) {
+	case bool:
+		return wrapFcfVal("booleanValue", val)
+	case int64:
+		return wrapFcfVal("integerValue", strconv.FormatInt(val, 10))
+	case float64:
+		return encodeDouble(val)
+	case time.Time:
+		return wrapFcfVal("timestampValue", val.UTC().Format(time.RFC3339Nano))
+	case string:
+		return wrapFcfVal("stringValue", val)
+	case []byte:
+		return wrapFcfVal("bytesValue", base64.StdEncoding.EncodeToString(val))
+	case DocumentRef:
+		return wrapFcfVal("referenceValue", val.Name())
+	case GeoPoint:
+		return wrapFcfVal("geoPointValue", map[string]interface{}{
+			"latitude":  val.Latitude,
+			"longitude": val.Longitude,
+		})
+	case Vector:
+		return encodeVector(val)
+	case []Node:
+		values := make([]interface{}, len(val))
+		for i, elem := range val {
+			values[i] = encodeNode(elem)
+		}
+		return wrapFcfVal("arrayValue", map[string]interface{}{"values": values})
+	case map[string]Node:
+		return wrapFcfVal("mapValue", map[string]interface{}{"fields": encodeNodeFields(val)})
+	}
+	return wrapFcfVal("nullValue", nil)
+}
+
+func encodeNodeFields(nodes map[string]Node) map[string]interface{} {
+	fields := make(map[string]interface{}, len(nodes))
+	for key, node := range nodes {
+		fields[key] = encodeNode(node)
+	}
+	return fields
+}
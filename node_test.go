@@ -0,0 +1,155 @@
+package fcf
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNode(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"null":   map[string]interface{}{"nullValue": nil},
+			"bool":   map[string]interface{}{"booleanValue": true},
+			"int":    map[string]interface{}{"integerValue": "9007199254740993"},
+			"double": map[string]interface{}{"doubleValue": 1.5},
+			"time":   map[string]interface{}{"timestampValue": "2019-02-03T01:07:05.5Z"},
+			"string": map[string]interface{}{"stringValue": "Zm9v"},
+			"bytes":  map[string]interface{}{"bytesValue": "Zm9v"},
+			"ref":    map[string]interface{}{"referenceValue": testDocName},
+			"geo":    map[string]interface{}{"geoPointValue": map[string]interface{}{"latitude": 1.5}},
+			"vector": vectorValue(map[string]interface{}{"doubleValue": 0.5}),
+			"array": map[string]interface{}{"arrayValue": map[string]interface{}{"values": []interface{}{
+				map[string]interface{}{"stringValue": "a"},
+				map[string]interface{}{"integerValue": "2"},
+			}}},
+			"map": map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{
+				"inner": map[string]interface{}{"booleanValue": false},
+			}}},
+		},
+	}
+
+	var doc Node
+	if err := fcfVal.Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Kind() != MapNode || doc.Len() != len(fcfVal.Fields) {
+		t.Fatalf("expected a map of %d fields, got %v of %d", len(fcfVal.Fields), doc.Kind(), doc.Len())
+	}
+	expectedKinds := map[string]NodeKind{
+		"null": NullNode, "bool": BooleanNode, "int": IntegerNode, "double": DoubleNode,
+		"time": TimestampNode, "string": StringNode, "bytes": BytesNode, "ref": ReferenceNode,
+		"geo": GeoPointNode, "vector": VectorNode, "array": ArrayNode, "map": MapNode,
+	}
+	for _, key := range doc.Keys() {
+		field, _ := doc.Field(key)
+		if field.Kind() != expectedKinds[key] {
+			t.Errorf("%s: expected %v, got %v", key, expectedKinds[key], field.Kind())
+		}
+	}
+
+	field := func(key string) Node {
+		n, ok := doc.Field(key)
+		if !ok {
+			t.Fatalf("missing field %s", key)
+		}
+		return n
+	}
+	if i, ok := field("int").AsInt(); !ok || i != 9007199254740993 {
+		t.Errorf("expected 9007199254740993, got %v", i)
+	}
+	if _, ok := field("int").AsDouble(); ok {
+		t.Error("expected an integer not to be a double")
+	}
+	if tm, ok := field("time").AsTime(); !ok || !tm.Equal(time.Date(2019, 2, 3, 1, 7, 5, 5e8, time.UTC)) {
+		t.Errorf("unexpected time %v", tm)
+	}
+	if s, ok := field("string").AsString(); !ok || s != "Zm9v" {
+		t.Errorf("expected Zm9v, got %q", s)
+	}
+	if b, ok := field("bytes").AsBytes(); !ok || string(b) != "foo" {
+		t.Errorf("expected foo, got %q", b)
+	}
+	if ref, ok := field("ref").AsRef(); !ok || ref.Name() != testDocName {
+		t.Errorf("expected %s, got %v", testDocName, ref)
+	}
+	if p, ok := field("geo").AsGeoPoint(); !ok || p != (GeoPoint{Latitude: 1.5}) {
+		t.Errorf("unexpected geo point %v", p)
+	}
+	if v, ok := field("vector").AsVector(); !ok || !reflect.DeepEqual(v, Vector{0.5}) {
+		t.Errorf("unexpected vector %v", v)
+	}
+	if array := field("array"); array.Len() != 2 || array.Index(1).Kind() != IntegerNode || !array.Index(5).IsNull() {
+		t.Errorf("unexpected array %v", array.Elems())
+	}
+	inner, _ := field("map").Field("inner")
+	if b, ok := inner.AsBool(); !ok || b {
+		t.Errorf("expected false, got %v", inner)
+	}
+	expected := []interface{}{"a", int64(2)}
+	if !reflect.DeepEqual(field("array").Interface(), expected) {
+		t.Errorf("expected %v, got %v", expected, field("array").Interface())
+	}
+
+	// encoding a node gives back the same types
+	fields, err := Encode(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fcfVal.Fields["geo"] = map[string]interface{}{"geoPointValue": map[string]interface{}{"latitude": 1.5, "longitude": 0.0}}
+	if !reflect.DeepEqual(fields, fcfVal.Fields) {
+		t.Errorf("expected %v, got %v", fcfVal.Fields, fields)
+	}
+}
+
+func TestNodeFields(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"Name":  map[string]interface{}{"stringValue": "alice"},
+			"Extra": map[string]interface{}{"referenceValue": testDocName},
+			"Tags": map[string]interface{}{"arrayValue": map[string]interface{}{"values": []interface{}{
+				map[string]interface{}{"bytesValue": "Zm9v"},
+			}}},
+		},
+	}
+	var userVal struct {
+		Name  string
+		Extra Node
+		Tags  []Node
+	}
+	if err := fcfVal.Decode(&userVal); err != nil {
+		t.Fatal(err)
+	}
+	if userVal.Extra.Kind() != ReferenceNode {
+		t.Errorf("expected a reference, got %v", userVal.Extra.Kind())
+	}
+	if len(userVal.Tags) != 1 || userVal.Tags[0].Kind() != BytesNode {
+		t.Errorf("expected a bytes node, got %v", userVal.Tags)
+	}
+
+	fcfVal.Fields["Extra"] = map[string]interface{}{"integerValue": "x"}
+	if err := fcfVal.Decode(&userVal); err == nil {
+		t.Error("expected an error decoding a malformed integer into a node")
+	}
+}
+
+func TestNodeIntegerBase(t *testing.T) {
+	// integers are decimal, whatever they're decoded into
+	for _, s := range []string{"0x10", "0o7", "1_000"} {
+		fcfVal := Value{Fields: map[string]interface{}{
+			"Field": map[string]interface{}{"integerValue": s},
+		}}
+		targets := []interface{}{
+			&struct{ Field Node }{},
+			&struct{ Field int }{},
+			&struct{ Field uint }{},
+			&struct{ Field big.Int }{},
+		}
+		for _, target := range targets {
+			if err := fcfVal.Decode(target); err == nil {
+				t.Errorf("expected error decoding %q into %T", s, target)
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package fcf
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// PatchOperation is a single RFC 6902 JSON Patch operation
+type PatchOperation struct {
+	// Op is one of "add", "remove" or "replace"
+	Op string
+	// Path is a JSON Pointer (RFC 6901) to the target of the operation
+	Path string
+	// Value is the new value for "add" and "replace" operations
+	Value interface{}
+}
+
+// MarshalJSON encodes the operation, including a null value
+// for "add" and "replace" but no value at all for "remove"
+func (op PatchOperation) MarshalJSON() ([]byte, error) {
+	if op.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{op.Op, op.Path})
+	}
+	return json.Marshal(struct {
+		Op    string      `json:"op"`
+		Path  string      `json:"path"`
+		Value interface{} `json:"value"`
+	}{op.Op, op.Path, op.Value})
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+func jsonPointer(elems []interface{}) string {
+	var b strings.Builder
+	for _, elem := range elems {
+		b.WriteByte('/')
+		switch elem := elem.(type) {
+		case int:
+			b.WriteString(strconv.Itoa(elem))
+		case string:
+			b.WriteString(pointerEscaper.Replace(elem))
+		}
+	}
+	return b.String()
+}
+
+// JSONPatch returns an RFC 6902 JSON Patch that transforms the old document
+// into the new one. The patch applies to the documents as Decode
+// would produce them for a map[string]interface{}, so it can be
+// consumed by systems that know nothing about Firestore.
+// Non-finite doubles, which JSON can't represent as numbers, are given
+// as the strings "NaN", "Infinity" and "-Infinity", as in protojson.
+func (e Event) JSONPatch() ([]PatchOperation, error) {
+	changes, err := Diff(e.OldValue, e.Value)
+	if err != nil {
+		return nil, err
+	}
+	patch := make([]PatchOperation, 0, len(changes))
+	for _, change := range changes {
+		op := PatchOperation{Path: jsonPointer(change.elems), Value: jsonSafe(change.New)}
+		switch change.Type {
+		case Added:
+			op.Op = "add"
+		case Removed:
+			op.Op = "remove"
+		case Modified:
+			op.Op = "replace"
+		}
+		patch = append(patch, op)
+	}
+	return patch, nil
+}
+
+// MergePatch returns an RFC 7386 JSON Merge Patch that transforms the old
+// document into the new one, using the same representation as JSONPatch.
+// As with any merge patch, changed arrays are replaced wholesale, and
+// fields set to null cannot be distinguished from removed fields.
+func (e Event) MergePatch() (map[string]interface{}, error) {
+	oldDoc, err := decodeDocument(e.OldValue)
+	if err != nil {
+		return nil, err
+	}
+	newDoc, err := decodeDocument(e.Value)
+	if err != nil {
+		return nil, err
+	}
+	return mergePatch(oldDoc, newDoc), nil
+}
+
+func decodeDocument(v Value) (map[string]interface{}, error) {
+	doc := map[string]interface{}{}
+	if !v.Exists() {
+		return doc, nil
+	}
+	if err := v.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return jsonSafe(doc).(map[string]interface{}), nil
+}
+
+// jsonSafe replaces the non-finite doubles in a decoded value
+// with their protojson strings, which also makes NaN equal itself
+func jsonSafe(val interface{}) interface{} {
+	switch val := val.(type) {
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			return formatDouble(val)
+		}
+	case Vector:
+		for _, f := range val {
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				elems := make([]interface{}, len(val))
+				for i, f := range val {
+					elems[i] = jsonSafe(f)
+				}
+				return elems
+			}
+		}
+	case []interface{}:
+		for i, elem := range val {
+			val[i] = jsonSafe(elem)
+		}
+	case map[string]interface{}:
+		for key, field := range val {
+			val[key] = jsonSafe(field)
+		}
+	}
+	return val
+}
+
+func mergePatch(oldDoc, newDoc map[string]interface{}) map[string]interface{} {
+	patch := map[string]interface{}{}
+	for key := range oldDoc {
+		if _, ok := newDoc[key]; !ok {
+			patch[key] = nil
+		}
+	}
+	for key, newVal := range newDoc {
+		oldVal, ok := oldDoc[key]
+		if !ok {
+			patch[key] = newVal
+			continue
+		}
+		oldMap, oldIsMap := oldVal.(map[string]interface{})
+		newMap, newIsMap := newVal.(map[string]interface{})
+		if oldIsMap && newIsMap {
+			if nested := mergePatch(oldMap, newMap); len(nested) > 0 {
+				patch[key] = nested
+			}
+			continue
+		}
+		if !reflect.DeepEqual(oldVal, newVal) {
+			patch[key] = newVal
+		}
+	}
+	return patch
+}
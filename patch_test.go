@@ -0,0 +1,164 @@
+package fcf
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func patchTestEvent(t *testing.T) Event {
+	type doc struct {
+		Name    string
+		Age     int
+		Nick    *string
+		Tags    []string
+		Address map[string]string
+	}
+	nick := "al"
+	oldVal, err := NewValue(doc{
+		Name:    "alice",
+		Age:     30,
+		Nick:    &nick,
+		Tags:    []string{"a", "b", "c"},
+		Address: map[string]string{"city": "Springfield", "zip/code": "12345"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newVal, err := NewValue(doc{
+		Name:    "alice",
+		Age:     31,
+		Tags:    []string{"a"},
+		Address: map[string]string{"city": "Springfield", "zip/code": "54321"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldVal.Name = testDocName
+	newVal.Name = testDocName
+	return Event{OldValue: oldVal, Value: newVal}
+}
+
+func assertJSON(t *testing.T, expected string, val interface{}) {
+	actual, err := json.Marshal(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e, a interface{}
+	if err := json.Unmarshal([]byte(expected), &e); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(actual, &a); err != nil {
+		t.Fatal(err)
+	}
+	ej, _ := json.Marshal(e)
+	aj, _ := json.Marshal(a)
+	if string(ej) != string(aj) {
+		t.Errorf("expected %s, got %s", ej, aj)
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	patch, err := patchTestEvent(t).JSONPatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSON(t, `[
+		{"op": "replace", "path": "/Address/zip~1code", "value": "54321"},
+		{"op": "replace", "path": "/Age", "value": 31},
+		{"op": "replace", "path": "/Nick", "value": null},
+		{"op": "remove", "path": "/Tags/2"},
+		{"op": "remove", "path": "/Tags/1"}
+	]`, patch)
+}
+
+func TestJSONPatchCreate(t *testing.T) {
+	patch, err := Event{Value: testDoc("alice")}.JSONPatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSON(t, `[{"op": "add", "path": "/Name", "value": "alice"}]`, patch)
+
+	patch, err = Event{}.JSONPatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSON(t, `[]`, patch)
+}
+
+func TestMergePatch(t *testing.T) {
+	patch, err := patchTestEvent(t).MergePatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSON(t, `{
+		"Address": {"zip/code": "54321"},
+		"Age": 31,
+		"Nick": null,
+		"Tags": ["a"]
+	}`, patch)
+}
+
+func TestMergePatchDelete(t *testing.T) {
+	patch, err := Event{OldValue: testDoc("alice")}.MergePatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSON(t, `{"Name": null}`, patch)
+}
+
+func TestJSONPatchVector(t *testing.T) {
+	type doc struct {
+		Embedding Vector
+		Same      Vector
+	}
+	oldVal, err := NewValue(doc{Embedding: Vector{1, 2}, Same: Vector{0.5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newVal, err := NewValue(doc{Embedding: Vector{1, 3}, Same: Vector{0.5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := Event{OldValue: oldVal, Value: newVal}
+
+	patch, err := event.JSONPatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSON(t, `[{"op": "replace", "path": "/Embedding", "value": [1, 3]}]`, patch)
+
+	merge, err := event.MergePatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSON(t, `{"Embedding": [1, 3]}`, merge)
+}
+
+func TestPatchNonFiniteDoubles(t *testing.T) {
+	oldVal := Value{Fields: map[string]interface{}{
+		"NaN":       map[string]interface{}{"doubleValue": "NaN"},
+		"Inf":       map[string]interface{}{"doubleValue": 1.5},
+		"Embedding": vectorValue(map[string]interface{}{"doubleValue": 1.0}),
+	}}
+	newVal := Value{Fields: map[string]interface{}{
+		"NaN":       map[string]interface{}{"doubleValue": "NaN"},
+		"Inf":       map[string]interface{}{"doubleValue": "-Infinity"},
+		"Embedding": vectorValue(map[string]interface{}{"doubleValue": "Infinity"}),
+	}}
+	event := Event{OldValue: oldVal, Value: newVal}
+
+	patch, err := event.JSONPatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSON(t, `[
+		{"op": "replace", "path": "/Embedding", "value": ["Infinity"]},
+		{"op": "replace", "path": "/Inf", "value": "-Infinity"}
+	]`, patch)
+
+	merge, err := event.MergePatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSON(t, `{"Embedding": ["Infinity"], "Inf": "-Infinity"}`, merge)
+}
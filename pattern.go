@@ -0,0 +1,110 @@
+package fcf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MatchPattern matches a document name against a trigger path pattern
+// such as users/{uid}/orders/{orderId} and returns the values bound to
+// each wildcard. A wildcard of the form {name=**} matches any number of
+// segments, and its value is those segments joined with slashes.
+// name may be a full resource name or a path relative to the database root.
+// The second return value reports whether the name matched.
+func MatchPattern(pattern, name string) (map[string]string, bool) {
+	p, err := compilePattern(pattern)
+	if err != nil {
+		return nil, false
+	}
+	return p.match(name)
+}
+
+type patternSegment struct {
+	literal  string
+	wildcard string
+	multi    bool
+}
+
+type pathPattern []patternSegment
+
+func compilePattern(pattern string) (pathPattern, error) {
+	var p pathPattern
+	seen := map[string]bool{}
+	multi := false
+	for _, s := range strings.Split(strings.Trim(pattern, "/"), "/") {
+		if s == "" {
+			return nil, fmt.Errorf("Invalid pattern %q: empty segment", pattern)
+		}
+		if !strings.HasPrefix(s, "{") || !strings.HasSuffix(s, "}") {
+			if strings.ContainsAny(s, "{}") {
+				return nil, fmt.Errorf("Invalid pattern %q: malformed wildcard %q", pattern, s)
+			}
+			p = append(p, patternSegment{literal: s})
+			continue
+		}
+		segment := patternSegment{wildcard: s[1 : len(s)-1]}
+		if strings.HasSuffix(segment.wildcard, "=**") {
+			if multi {
+				return nil, fmt.Errorf("Invalid pattern %q: only one multi segment wildcard is allowed", pattern)
+			}
+			segment.wildcard = strings.TrimSuffix(segment.wildcard, "=**")
+			segment.multi, multi = true, true
+		}
+		if segment.wildcard == "" || strings.ContainsAny(segment.wildcard, "{}=*") {
+			return nil, fmt.Errorf("Invalid pattern %q: malformed wildcard %q", pattern, s)
+		}
+		if seen[segment.wildcard] {
+			return nil, fmt.Errorf("Invalid pattern %q: duplicate wildcard %q", pattern, segment.wildcard)
+		}
+		seen[segment.wildcard] = true
+		p = append(p, segment)
+	}
+	return p, nil
+}
+
+// documentPath returns the path of name relative to the database root
+func documentPath(name string) string {
+	if ref, err := ParseDocumentRef(name); err == nil {
+		return ref.Path
+	}
+	return strings.Trim(name, "/")
+}
+
+func (p pathPattern) match(name string) (map[string]string, bool) {
+	path := documentPath(name)
+	if path == "" {
+		return nil, false
+	}
+	segments := strings.Split(path, "/")
+	params := map[string]string{}
+	if !p.matchSegments(segments, params) {
+		return nil, false
+	}
+	return params, true
+}
+
+func (p pathPattern) matchSegments(segments []string, params map[string]string) bool {
+	for i, ps := range p {
+		if ps.multi {
+			// the multi segment wildcard takes whatever
+			// the segments after it leave behind
+			rest := p[i+1:]
+			n := len(segments) - len(rest)
+			if n < 0 || !rest.matchSegments(segments[n:], params) {
+				return false
+			}
+			params[ps.wildcard] = strings.Join(segments[:n], "/")
+			return true
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ps.wildcard != "" {
+			params[ps.wildcard] = segments[0]
+		} else if ps.literal != segments[0] {
+			return false
+		}
+		segments = segments[1:]
+	}
+	return len(segments) == 0
+}
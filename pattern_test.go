@@ -0,0 +1,76 @@
+package fcf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValuePath(t *testing.T) {
+	v := Value{Name: "projects/project-name/databases/(default)/documents/users/alice/orders/1234"}
+	ref, err := v.Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.Project != "project-name" || ref.Database != DefaultDatabase || ref.ID != "1234" {
+		t.Errorf("unexpected path %+v", ref)
+	}
+	expected := []string{"users", "alice", "orders", "1234"}
+	if !reflect.DeepEqual(ref.Segments(), expected) {
+		t.Errorf("expected %v, got %v", expected, ref.Segments())
+	}
+	if _, err := (Value{}).Path(); err == nil {
+		t.Errorf("expected error parsing an empty name")
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		name     string
+		expected map[string]string
+	}{
+		{"users/{uid}", testDocName, map[string]string{"uid": "alice"}},
+		{"users/{uid}/orders/{orderId}", "users/alice/orders/1234",
+			map[string]string{"uid": "alice", "orderId": "1234"}},
+		{"users/{uid}/orders/{orderId}", "/users/alice/orders/1234/",
+			map[string]string{"uid": "alice", "orderId": "1234"}},
+		{"users/alice", "users/alice", map[string]string{}},
+		{"{path=**}", "users/alice/orders/1234", map[string]string{"path": "users/alice/orders/1234"}},
+		{"users/{uid}/{rest=**}", "users/alice/orders/1234/items/1",
+			map[string]string{"uid": "alice", "rest": "orders/1234/items/1"}},
+		{"{prefix=**}/orders/{orderId}", "users/alice/orders/1234",
+			map[string]string{"prefix": "users/alice", "orderId": "1234"}},
+		{"users/{uid}/{rest=**}", "users/alice", map[string]string{"uid": "alice", "rest": ""}},
+	}
+	for _, test := range tests {
+		params, ok := MatchPattern(test.pattern, test.name)
+		if !ok {
+			t.Errorf("%s: expected %s to match", test.pattern, test.name)
+			continue
+		}
+		if !reflect.DeepEqual(params, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.pattern, test.expected, params)
+		}
+	}
+}
+
+func TestMatchPatternMismatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+	}{
+		{"users/{uid}", "users/alice/orders/1234"},
+		{"users/{uid}/orders/{orderId}", "users/alice"},
+		{"users/{uid}", "groups/admins"},
+		{"users/{uid}", ""},
+		{"users/{uid", "users/alice"},
+		{"users/{uid}/orders/{uid}", "users/alice/orders/1234"},
+		{"{a=**}/{b=**}", "users/alice"},
+		{"users//{uid}", "users/alice"},
+	}
+	for _, test := range tests {
+		if params, ok := MatchPattern(test.pattern, test.name); ok {
+			t.Errorf("%s: expected %s not to match, got %v", test.pattern, test.name, params)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package fcf
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// DefaultDatabase is the ID of a project's default Firestore database
+const DefaultDatabase = "(default)"
+
+var documentRefType = reflect.TypeOf(DocumentRef{})
+
+// DocumentRef identifies a Firestore document.
+// Decode referenceValues into it to keep the project and database,
+// which are dropped when decoding a referenceValue into a string.
+// The zero DocumentRef, which a nullValue decodes to, is encoded as null.
+type DocumentRef struct {
+	Project  string
+	Database string
+	// Path is the document's path relative to the database root,
+	// e.g. users/alice/orders/1234
+	Path string
+	// ID is the last element of Path
+	ID string
+}
+
+// ParseDocumentRef parses a full document resource name of the form
+// projects/{project}/databases/{database}/documents/{path}
+func ParseDocumentRef(name string) (DocumentRef, error) {
+	parts := strings.SplitN(strings.TrimPrefix(name, "/"), "/", 6)
+	if len(parts) != 6 || parts[0] != "projects" || parts[2] != "databases" || parts[4] != "documents" ||
+		parts[1] == "" || parts[3] == "" {
+		return DocumentRef{}, fmt.Errorf("malformed document name %q", name)
+	}
+	segments := strings.Split(parts[5], "/")
+	if len(segments)%2 != 0 {
+		return DocumentRef{}, fmt.Errorf("malformed document name %q: path must have an even number of segments", name)
+	}
+	for _, segment := range segments {
+		if segment == "" {
+			return DocumentRef{}, fmt.Errorf("malformed document name %q: empty path segment", name)
+		}
+	}
+	return DocumentRef{
+		Project:  parts[1],
+		Database: parts[3],
+		Path:     parts[5],
+		ID:       segments[len(segments)-1],
+	}, nil
+}
+
+// Name returns the full resource name of the document,
+// which is how Firestore stores it in a referenceValue
+func (r DocumentRef) Name() string {
+	database := r.Database
+	if database == "" {
+		database = DefaultDatabase
+	}
+	return fmt.Sprintf("projects/%s/databases/%s/documents/%s", r.Project, database, r.Path)
+}
+
+func (r DocumentRef) String() string {
+	return r.Name()
+}
+
+// Collection returns the ID of the collection containing the document
+func (r DocumentRef) Collection() string {
+	segments := strings.Split(r.Path, "/")
+	if len(segments) < 2 {
+		return ""
+	}
+	return segments[len(segments)-2]
+}
+
+// Parent returns the document containing this document's collection,
+// or nil if the document is in a root level collection
+func (r DocumentRef) Parent() *DocumentRef {
+	segments := strings.Split(r.Path, "/")
+	if len(segments) < 4 {
+		return nil
+	}
+	segments = segments[:len(segments)-2]
+	return &DocumentRef{
+		Project:  r.Project,
+		Database: r.Database,
+		Path:     strings.Join(segments, "/"),
+		ID:       segments[len(segments)-1],
+	}
+}
+
+// Path parses the document's resource name
+func (v Value) Path() (DocumentRef, error) {
+	return ParseDocumentRef(v.Name)
+}
+
+// Segments returns the elements of the document's path,
+// alternating between collection and document IDs
+func (r DocumentRef) Segments() []string {
+	return strings.Split(r.Path, "/")
+}
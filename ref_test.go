@@ -0,0 +1,142 @@
+package fcf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDocumentRef(t *testing.T) {
+	ref, err := ParseDocumentRef("projects/project-name/databases/named-db/documents/col1/doc1/col2/doc2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := DocumentRef{
+		Project:  "project-name",
+		Database: "named-db",
+		Path:     "col1/doc1/col2/doc2",
+		ID:       "doc2",
+	}
+	if ref != expected {
+		t.Errorf("expected %+v, got %+v", expected, ref)
+	}
+	if ref.Collection() != "col2" {
+		t.Errorf("expected collection %q, got %q", "col2", ref.Collection())
+	}
+	parent := ref.Parent()
+	if parent == nil || parent.Path != "col1/doc1" || parent.ID != "doc1" || parent.Database != "named-db" {
+		t.Errorf("expected parent col1/doc1, got %+v", parent)
+	}
+	if parent.Collection() != "col1" {
+		t.Errorf("expected collection %q, got %q", "col1", parent.Collection())
+	}
+	if grandparent := parent.Parent(); grandparent != nil {
+		t.Errorf("expected no parent, got %+v", grandparent)
+	}
+	if ref.Name() != "projects/project-name/databases/named-db/documents/col1/doc1/col2/doc2" {
+		t.Errorf("unexpected name %q", ref.Name())
+	}
+}
+
+func TestParseDocumentRefErrors(t *testing.T) {
+	names := []string{
+		"",
+		"col1/doc1",
+		"projects/p/databases/(default)/documents",
+		"projects/p/databases/(default)/documents/col1",
+		"projects/p/databases/(default)/documents/col1//doc2",
+		"projects//databases/(default)/documents/col1/doc1",
+		"projects/p/dbs/(default)/documents/col1/doc1",
+	}
+	for _, name := range names {
+		if ref, err := ParseDocumentRef(name); err == nil {
+			t.Errorf("%q: expected error, got %+v", name, ref)
+		}
+	}
+}
+
+func TestDecodeDocumentRef(t *testing.T) {
+	fullVal := "projects/project-name/databases/named-db/documents/col1/doc1"
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"Field": map[string]interface{}{"referenceValue": fullVal},
+		},
+	}
+
+	userVal := &struct {
+		Field  DocumentRef
+		Ptr    *DocumentRef `fcf:"Field"`
+		String string       `fcf:"Field"`
+	}{}
+	if err := fcfVal.Decode(userVal); err != nil {
+		t.Fatal(err)
+	}
+	if userVal.Field.Name() != fullVal {
+		t.Errorf("expected %q, got %q", fullVal, userVal.Field.Name())
+	}
+	if userVal.Ptr == nil || *userVal.Ptr != userVal.Field {
+		t.Errorf("expected %+v, got %+v", userVal.Field, userVal.Ptr)
+	}
+	if userVal.String != "/col1/doc1" {
+		t.Errorf("expected %q, got %q", "/col1/doc1", userVal.String)
+	}
+
+	encoded, err := NewValue(struct{ Field DocumentRef }{userVal.Field})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoded.Fields["Field"].(map[string]interface{})["referenceValue"] != fullVal {
+		t.Errorf("expected %q, got %v", fullVal, encoded.Fields["Field"])
+	}
+}
+
+func TestZeroDocumentRef(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"Field": map[string]interface{}{"nullValue": nil},
+		},
+	}
+	var userVal struct {
+		Field DocumentRef
+		Empty DocumentRef `fcf:",omitempty"`
+	}
+	if err := fcfVal.Decode(&userVal); err != nil {
+		t.Fatal(err)
+	}
+
+	// a zero ref round trips as null, and omitempty leaves it out
+	encoded, err := NewValue(userVal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(encoded.Fields, fcfVal.Fields) {
+		t.Errorf("expected %v, got %v", fcfVal.Fields, encoded.Fields)
+	}
+	if err := encoded.Decode(&userVal); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := Diff(fcfVal, encoded)
+	if err != nil || len(changes) != 0 {
+		t.Errorf("expected no changes, got %v, %v", changes, err)
+	}
+}
+
+func TestDecodeRefMap(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"Field": map[string]interface{}{"mapValue": map[string]interface{}{
+				"fields": map[string]interface{}{
+					"a": map[string]interface{}{"referenceValue": "projects/p/databases/(default)/documents/col1/doc1"},
+				},
+			}},
+		},
+	}
+	userVal := &struct {
+		Field map[string]*DocumentRef
+	}{}
+	if err := fcfVal.Decode(userVal); err != nil {
+		t.Fatal(err)
+	}
+	if userVal.Field["a"] == nil || userVal.Field["a"].ID != "doc1" {
+		t.Errorf("expected doc1, got %+v", userVal.Field["a"])
+	}
+}
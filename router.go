@@ -0,0 +1,84 @@
+package fcf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNoRoute is returned by Router.Dispatch when no route matches an event
+var ErrNoRoute = errors.New("no route matches event")
+
+// Handler handles an event routed to it by a Router.
+// params holds the values bound to the wildcards in the route's pattern.
+type Handler func(ctx context.Context, e Event, params map[string]string) error
+
+// Router dispatches events to handlers based on the event's kind
+// and the path of its document. The zero value is an empty Router.
+// Routes are tried in the order they were registered and only the
+// first matching route's handler is called. Register every route
+// before calling Dispatch; Dispatch itself is safe for concurrent use.
+type Router struct {
+	routes []route
+}
+
+type route struct {
+	pattern pathPattern
+	// kind is the kind of event handled, or Unknown for all kinds
+	kind    EventKind
+	handler Handler
+}
+
+func (r *Router) handle(kind EventKind, pattern string, h Handler) {
+	p, err := compilePattern(pattern)
+	if err != nil {
+		panic(err)
+	}
+	r.routes = append(r.routes, route{pattern: p, kind: kind, handler: h})
+}
+
+// OnCreate registers h to handle creates of documents matching pattern.
+// Patterns use the syntax described in MatchPattern.
+// It panics if pattern is invalid.
+func (r *Router) OnCreate(pattern string, h Handler) {
+	r.handle(Create, pattern, h)
+}
+
+// OnUpdate registers h to handle updates of documents matching pattern.
+// It panics if pattern is invalid.
+func (r *Router) OnUpdate(pattern string, h Handler) {
+	r.handle(Update, pattern, h)
+}
+
+// OnDelete registers h to handle deletes of documents matching pattern.
+// It panics if pattern is invalid.
+func (r *Router) OnDelete(pattern string, h Handler) {
+	r.handle(Delete, pattern, h)
+}
+
+// OnWrite registers h to handle creates, updates and deletes
+// of documents matching pattern. It panics if pattern is invalid.
+func (r *Router) OnWrite(pattern string, h Handler) {
+	r.handle(Unknown, pattern, h)
+}
+
+// Dispatch calls the handler of the first route matching e and
+// returns its error. If no route matches, it returns an error wrapping ErrNoRoute.
+func (r *Router) Dispatch(ctx context.Context, e Event) error {
+	kind := e.Kind()
+	name := e.Value.Name
+	if kind == Delete {
+		name = e.OldValue.Name
+	}
+	if kind != Unknown {
+		for _, rt := range r.routes {
+			if rt.kind != Unknown && rt.kind != kind {
+				continue
+			}
+			if params, ok := rt.pattern.match(name); ok {
+				return rt.handler(ctx, e, params)
+			}
+		}
+	}
+	return fmt.Errorf("%w: %v event for %q", ErrNoRoute, kind, name)
+}
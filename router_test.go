@@ -0,0 +1,90 @@
+package fcf
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const testOrderName = "projects/project-name/databases/(default)/documents/users/alice/orders/1234"
+
+func TestRouter(t *testing.T) {
+	var called string
+	var gotParams map[string]string
+	handler := func(name string) Handler {
+		return func(ctx context.Context, e Event, params map[string]string) error {
+			called, gotParams = name, params
+			return nil
+		}
+	}
+
+	var r Router
+	r.OnCreate("users/{uid}", handler("createUser"))
+	r.OnUpdate("users/{uid}", handler("updateUser"))
+	r.OnWrite("users/{uid}/orders/{orderId}", handler("writeOrder"))
+	r.OnDelete("{path=**}", handler("deleteAny"))
+
+	tests := []struct {
+		event    Event
+		handler  string
+		expected map[string]string
+	}{
+		{Event{Value: testDoc("alice")}, "createUser", map[string]string{"uid": "alice"}},
+		{Event{OldValue: testDoc("alice"), Value: testDoc("bob")}, "updateUser", map[string]string{"uid": "alice"}},
+		{Event{OldValue: testDoc("alice")}, "deleteAny", map[string]string{"path": "users/alice"}},
+		{Event{Value: Value{Name: testOrderName}}, "writeOrder", map[string]string{"uid": "alice", "orderId": "1234"}},
+		{Event{OldValue: Value{Name: testOrderName}}, "writeOrder", map[string]string{"uid": "alice", "orderId": "1234"}},
+	}
+	for _, test := range tests {
+		called, gotParams = "", nil
+		if err := r.Dispatch(context.Background(), test.event); err != nil {
+			t.Errorf("%s: %v", test.handler, err)
+			continue
+		}
+		if called != test.handler {
+			t.Errorf("expected %s to be called, got %q", test.handler, called)
+		}
+		if !reflect.DeepEqual(gotParams, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.handler, test.expected, gotParams)
+		}
+	}
+}
+
+func TestRouterNoRoute(t *testing.T) {
+	var r Router
+	r.OnCreate("users/{uid}", func(ctx context.Context, e Event, params map[string]string) error {
+		return nil
+	})
+	events := []Event{
+		{OldValue: testDoc("alice"), Value: testDoc("bob")},
+		{Value: Value{Name: testOrderName}},
+		{},
+	}
+	for _, e := range events {
+		if err := r.Dispatch(context.Background(), e); !errors.Is(err, ErrNoRoute) {
+			t.Errorf("expected %v, got %v", ErrNoRoute, err)
+		}
+	}
+}
+
+func TestRouterHandlerError(t *testing.T) {
+	handlerErr := errors.New("handler failed")
+	var r Router
+	r.OnWrite("users/{uid}", func(ctx context.Context, e Event, params map[string]string) error {
+		return handlerErr
+	})
+	if err := r.Dispatch(context.Background(), Event{Value: testDoc("alice")}); err != handlerErr {
+		t.Errorf("expected %v, got %v", handlerErr, err)
+	}
+}
+
+func TestRouterInvalidPattern(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic registering an invalid pattern")
+		}
+	}()
+	var r Router
+	r.OnCreate("users/{uid", nil)
+}
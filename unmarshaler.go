@@ -0,0 +1,113 @@
+package fcf
+
+import (
+	"encoding"
+	"reflect"
+)
+
+// Unmarshaler is implemented by types that decode themselves
+// from a firestore value. fcfType is the value's union type
+// (e.g. integerValue) and raw is the value as it appears in the event,
+// e.g. the string "42" for an integerValue or a map with a "fields" key
+// for a mapValue. Null values are passed as a nullValue with a nil raw value,
+// except that pointer fields are simply set to nil.
+type Unmarshaler interface {
+	UnmarshalFirestore(fcfType string, raw interface{}) error
+}
+
+var (
+	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// implementer returns the field's value as an iface if its type implements it,
+// along with the value to store in the field once its methods have been called
+func implementer(f field, iface reflect.Type) (interface{}, reflect.Value, bool) {
+	t := f.Type()
+	if t.Kind() == reflect.Ptr && t.Implements(iface) {
+		v := f.getOrInit()
+		if v.IsNil() {
+			v = reflect.New(t.Elem())
+		}
+		return v.Interface(), v, true
+	}
+	if t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface && reflect.PtrTo(t).Implements(iface) {
+		v := f.getOrInit()
+		if !v.CanAddr() {
+			ptr := reflect.New(t)
+			ptr.Elem().Set(v)
+			v = ptr.Elem()
+		}
+		return v.Addr().Interface(), v, true
+	}
+	return nil, reflect.Value{}, false
+}
+
+// unmarshalCustom decodes the field with its Unmarshaler, if it has one,
+// and reports whether it did
+func unmarshalCustom(f field) (bool, error) {
+	if f.FcfType() == "" {
+		// the raw members of a geoPointValue
+		return false, nil
+	}
+	if f.FcfType() == "nullValue" && f.Type().Kind() == reflect.Ptr {
+		return false, nil
+	}
+	u, v, ok := implementer(f, unmarshalerType)
+	if !ok {
+		return false, nil
+	}
+	if err := u.(Unmarshaler).UnmarshalFirestore(f.FcfType(), rawValue(f)); err != nil {
+		return true, err
+	}
+	f.Set(v)
+	return true, nil
+}
+
+// rawValue returns the field's value as it appears in the event,
+// or nil for a nullValue however the null was spelled
+func rawValue(f field) interface{} {
+	if f.FcfType() == "nullValue" || !f.Fcf().IsValid() {
+		return nil
+	}
+	return f.Fcf().Interface()
+}
+
+// unmarshalText decodes a stringValue or referenceValue field
+// with its encoding.TextUnmarshaler, if it has one, and reports whether it did.
+// A referenceValue's text is the document's full resource name.
+func unmarshalText(f field) (bool, error) {
+	if f.FcfType() != "stringValue" && f.FcfType() != "referenceValue" {
+		return false, nil
+	}
+	u, v, ok := implementer(f, textUnmarshalerType)
+	if !ok {
+		return false, nil
+	}
+	text, err := convString(f.Fcf())
+	if err != nil {
+		return true, err
+	}
+	if err := u.(encoding.TextUnmarshaler).UnmarshalText([]byte(text)); err != nil {
+		return true, err
+	}
+	f.Set(v)
+	return true, nil
+}
+
+// textMarshaler returns usrVal as an encoding.TextMarshaler if it implements one.
+// Pointers and interfaces are left for the caller to dereference.
+func textMarshaler(usrVal reflect.Value) (encoding.TextMarshaler, bool) {
+	switch usrVal.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return nil, false
+	}
+	if usrVal.Type().Implements(textMarshalerType) {
+		return usrVal.Interface().(encoding.TextMarshaler), true
+	}
+	if usrVal.CanAddr() && reflect.PtrTo(usrVal.Type()).Implements(textMarshalerType) {
+		return usrVal.Addr().Interface().(encoding.TextMarshaler), true
+	}
+	return nil, false
+}
@@ -0,0 +1,263 @@
+package fcf
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// cents decodes integers as whole units and strings as decimals
+type cents int64
+
+func (c *cents) UnmarshalFirestore(fcfType string, raw interface{}) error {
+	switch fcfType {
+	case "integerValue":
+		n, err := strconv.ParseInt(raw.(string), 10, 64)
+		*c = cents(n * 100)
+		return err
+	case "stringValue":
+		f, err := strconv.ParseFloat(raw.(string), 64)
+		*c = cents(f*100 + 0.5)
+		return err
+	}
+	return fmt.Errorf("unexpected %s", fcfType)
+}
+
+type color int
+
+const (
+	red color = iota + 1
+	green
+)
+
+func (c *color) UnmarshalFirestore(fcfType string, raw interface{}) error {
+	switch raw {
+	case "red":
+		*c = red
+	case "green":
+		*c = green
+	default:
+		return fmt.Errorf("unknown color %v", raw)
+	}
+	return nil
+}
+
+type point struct {
+	fields map[string]interface{}
+}
+
+func (p *point) UnmarshalFirestore(fcfType string, raw interface{}) error {
+	if fcfType != "mapValue" {
+		return fmt.Errorf("unexpected %s", fcfType)
+	}
+	p.fields = raw.(map[string]interface{})["fields"].(map[string]interface{})
+	return nil
+}
+
+func TestUnmarshaler(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"price": map[string]interface{}{"stringValue": "12.34"},
+			"total": map[string]interface{}{"integerValue": "5"},
+			"color": map[string]interface{}{"stringValue": "green"},
+			"ptr":   map[string]interface{}{"stringValue": "red"},
+			"null":  map[string]interface{}{"nullValue": nil},
+			"colors": map[string]interface{}{"arrayValue": map[string]interface{}{"values": []interface{}{
+				map[string]interface{}{"stringValue": "red"},
+				map[string]interface{}{"stringValue": "green"},
+			}}},
+			"byName": map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{
+				"a": map[string]interface{}{"stringValue": "red"},
+			}}},
+			"point": map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{
+				"x": map[string]interface{}{"integerValue": "1"},
+			}}},
+		},
+	}
+
+	null := red
+	userVal := struct {
+		Price  cents            `fcf:"price"`
+		Total  cents            `fcf:"total"`
+		Color  color            `fcf:"color"`
+		Ptr    *color           `fcf:"ptr"`
+		Null   *color           `fcf:"null"`
+		Colors []color          `fcf:"colors"`
+		ByName map[string]color `fcf:"byName"`
+		Point  point            `fcf:"point"`
+	}{Null: &null}
+	if err := fcfVal.Decode(&userVal); err != nil {
+		t.Fatal(err)
+	}
+	if userVal.Price != 1234 || userVal.Total != 500 {
+		t.Errorf("expected 1234 and 500 cents, got %d and %d", userVal.Price, userVal.Total)
+	}
+	if userVal.Color != green || userVal.Ptr == nil || *userVal.Ptr != red {
+		t.Errorf("expected green and red, got %v and %v", userVal.Color, userVal.Ptr)
+	}
+	if userVal.Null != nil {
+		t.Errorf("expected nil pointer, got %v", *userVal.Null)
+	}
+	if !reflect.DeepEqual(userVal.Colors, []color{red, green}) {
+		t.Errorf("expected [red green], got %v", userVal.Colors)
+	}
+	if !reflect.DeepEqual(userVal.ByName, map[string]color{"a": red}) {
+		t.Errorf("expected map of red, got %v", userVal.ByName)
+	}
+	expected := map[string]interface{}{"x": map[string]interface{}{"integerValue": "1"}}
+	if !reflect.DeepEqual(userVal.Point.fields, expected) {
+		t.Errorf("expected %v, got %v", expected, userVal.Point.fields)
+	}
+}
+
+func TestUnmarshalerRoot(t *testing.T) {
+	var p point
+	if err := testDoc("alice").Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.fields["Name"]; !ok {
+		t.Errorf("expected Name field, got %v", p.fields)
+	}
+}
+
+func TestUnmarshalerError(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"color": map[string]interface{}{"stringValue": "blue"},
+		},
+	}
+	var userVal struct {
+		Color color `fcf:"color"`
+	}
+	err := fcfVal.Decode(&userVal)
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("expected a *DecodeError, got %v", err)
+	}
+	if decodeErr.Path != "Color" || decodeErr.FcfType != "stringValue" {
+		t.Errorf("unexpected error %v", decodeErr)
+	}
+}
+
+// level is stored by name
+type level int
+
+func (l level) MarshalText() ([]byte, error) {
+	switch l {
+	case 1:
+		return []byte("low"), nil
+	case 2:
+		return []byte("high"), nil
+	}
+	return nil, fmt.Errorf("invalid level %d", int(l))
+}
+
+func (l *level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return fmt.Errorf("unknown level %q", text)
+	}
+	return nil
+}
+
+// docName keeps the full name of a referenceValue
+type docName struct {
+	name string
+}
+
+func (n *docName) UnmarshalText(text []byte) error {
+	n.name = string(text)
+	return nil
+}
+
+func TestTextUnmarshaler(t *testing.T) {
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"ip":    map[string]interface{}{"stringValue": "10.0.0.1"},
+			"level": map[string]interface{}{"stringValue": "high"},
+			"levels": map[string]interface{}{"arrayValue": map[string]interface{}{"values": []interface{}{
+				map[string]interface{}{"stringValue": "low"},
+			}}},
+			"ref": map[string]interface{}{"referenceValue": testDocName},
+		},
+	}
+	type doc struct {
+		IP     net.IP  `fcf:"ip"`
+		Level  *level  `fcf:"level"`
+		Levels []level `fcf:"levels"`
+		Ref    docName `fcf:"ref"`
+	}
+	var userVal doc
+	if err := fcfVal.Decode(&userVal); err != nil {
+		t.Fatal(err)
+	}
+	if !userVal.IP.Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Errorf("expected 10.0.0.1, got %v", userVal.IP)
+	}
+	if userVal.Level == nil || *userVal.Level != 2 {
+		t.Errorf("expected high level, got %v", userVal.Level)
+	}
+	if !reflect.DeepEqual(userVal.Levels, []level{1}) {
+		t.Errorf("expected [low], got %v", userVal.Levels)
+	}
+	if userVal.Ref.name != testDocName {
+		t.Errorf("expected %q, got %q", testDocName, userVal.Ref.name)
+	}
+
+	fields, err := Encode(struct {
+		IP     net.IP  `fcf:"ip"`
+		Level  *level  `fcf:"level"`
+		Levels []level `fcf:"levels"`
+	}{userVal.IP, userVal.Level, userVal.Levels})
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(fcfVal.Fields, "ref")
+	if !reflect.DeepEqual(fields, fcfVal.Fields) {
+		t.Errorf("expected %v, got %v", fcfVal.Fields, fields)
+	}
+
+	fcfVal.Fields["level"] = map[string]interface{}{"stringValue": "medium"}
+	var decodeErr *DecodeError
+	if err := fcfVal.Decode(&userVal); !errors.As(err, &decodeErr) || decodeErr.Path != "Level" {
+		t.Errorf("expected a *DecodeError for Level, got %v", err)
+	}
+	if _, err := Encode(struct{ Level level }{3}); err == nil {
+		t.Error("expected an error encoding an invalid level")
+	}
+}
+
+// rawRecorder records what UnmarshalFirestore was passed
+type rawRecorder struct {
+	fcfType string
+	raw     interface{}
+}
+
+func (r *rawRecorder) UnmarshalFirestore(fcfType string, raw interface{}) error {
+	r.fcfType, r.raw = fcfType, raw
+	return nil
+}
+
+func TestUnmarshalerNull(t *testing.T) {
+	for _, null := range []interface{}{nil, "NULL_VALUE", 0} {
+		fcfVal := Value{
+			Fields: map[string]interface{}{
+				"Field": map[string]interface{}{"nullValue": null},
+			},
+		}
+		var userVal struct{ Field rawRecorder }
+		if err := fcfVal.Decode(&userVal); err != nil {
+			t.Fatal(err)
+		}
+		if userVal.Field.fcfType != "nullValue" || userVal.Field.raw != nil {
+			t.Errorf("%#v: expected a nullValue with a nil raw value, got %+v", null, userVal.Field)
+		}
+	}
+}
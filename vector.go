@@ -0,0 +1,149 @@
+package fcf
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// Vector is a Firestore vector embedding. Firestore stores vectors as
+// a mapValue with a __type__ of __vector__ and the elements in its value.
+// Vectors decode into Vector, []float64 and []float32 fields, and into
+// interface{} fields as a Vector. Encode stores a Vector as a vector,
+// and other slices of floats as arrays unless their field is tagged
+// with the vector option (fcf:"name,vector").
+type Vector []float64
+
+var vectorType = reflect.TypeOf(Vector(nil))
+
+const (
+	vectorTypeKey   = "__type__"
+	vectorTypeValue = "__vector__"
+	vectorValueKey  = "value"
+)
+
+// isVector reports whether the fields of a mapValue are those of a vector
+func isVector(fields reflect.Value) bool {
+	typeVal := fields.MapIndex(reflect.ValueOf(vectorTypeKey))
+	if !typeVal.IsValid() {
+		return false
+	}
+	val, fcfType, err := unwrapFcfVal(typeVal)
+	return err == nil && fcfType == "stringValue" &&
+		val.Kind() == reflect.String && val.String() == vectorTypeValue
+}
+
+// vectorElems returns the elements of a vector given the fields of its mapValue
+func vectorElems(fields reflect.Value) ([]float64, error) {
+	wrapped := fields.MapIndex(reflect.ValueOf(vectorValueKey))
+	if !wrapped.IsValid() {
+		return nil, fmt.Errorf("malformed firestore vector: missing %q", vectorValueKey)
+	}
+	val, fcfType, err := unwrapFcfVal(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	if fcfType != "arrayValue" {
+		return nil, fmt.Errorf("malformed firestore vector: expected an arrayValue, got %s", fcfType)
+	}
+	values, err := getContainer(val, fcfType)
+	if err != nil {
+		return nil, err
+	}
+	elems := make([]float64, values.Len())
+	for i := range elems {
+		val, fcfType, err := unwrapFcfVal(values.Index(i))
+		if err != nil {
+			return nil, fmt.Errorf("vector element %d: %v", i, err)
+		}
+		switch fcfType {
+		case "doubleValue":
+			elems[i], err = convDouble(val)
+		case "integerValue":
+			var s string
+			if s, err = convInteger(val); err == nil {
+				elems[i], err = strconv.ParseFloat(s, 64)
+			}
+		default:
+			err = fmt.Errorf("expected a number, got %s", fcfType)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("vector element %d: %v", i, err)
+		}
+	}
+	return elems, nil
+}
+
+// unmarshalVector decodes a vector into a slice of floats or an empty
+// interface, if the field is one of those and holds a vector,
+// and reports whether it did
+func (d *decodeState) unmarshalVector(f field) (bool, error) {
+	t := f.Type()
+	isInterface := t.Kind() == reflect.Interface && t.NumMethod() == 0
+	isFloatSlice := t.Kind() == reflect.Slice &&
+		(t.Elem().Kind() == reflect.Float32 || t.Elem().Kind() == reflect.Float64)
+	if f.FcfType() != "mapValue" || !(isInterface || isFloatSlice) {
+		return false, nil
+	}
+	fields, err := getContainer(f.Fcf(), f.FcfType())
+	if err != nil || !isVector(fields) {
+		return false, nil
+	}
+	elems, err := vectorElems(fields)
+	if err != nil {
+		return true, err
+	}
+	if isInterface {
+		f.Set(reflect.ValueOf(Vector(elems)))
+		return true, nil
+	}
+	slice := reflect.MakeSlice(t, len(elems), len(elems))
+	for i, elem := range elems {
+		val := reflect.ValueOf(elem)
+		if t.Elem().Kind() == reflect.Float32 {
+			if val, err = convDoubleToFloat32(val, t.Elem(), d.lossyNumbers); err != nil {
+				return true, fmt.Errorf("vector element %d: %v", i, err)
+			}
+		}
+		slice.Index(i).SetFloat(val.Float())
+	}
+	f.Set(slice)
+	return true, nil
+}
+
+// encodeVector stores v in the wire format of a firestore vector
+func encodeVector(v Vector) map[string]interface{} {
+	values := make([]interface{}, len(v))
+	for i, elem := range v {
+		values[i] = encodeDouble(elem)
+	}
+	return wrapFcfVal("mapValue", map[string]interface{}{
+		"fields": map[string]interface{}{
+			vectorTypeKey:  wrapFcfVal("stringValue", vectorTypeValue),
+			vectorValueKey: wrapFcfVal("arrayValue", map[string]interface{}{"values": values}),
+		},
+	})
+}
+
+// encodeVectorField stores a slice of floats from a field
+// tagged with the vector option as a vector
+func encodeVectorField(usrVal reflect.Value, name string) (interface{}, error) {
+	for usrVal.Kind() == reflect.Ptr {
+		if usrVal.IsNil() {
+			return wrapFcfVal("nullValue", nil), nil
+		}
+		usrVal = usrVal.Elem()
+	}
+	if usrVal.Kind() != reflect.Slice ||
+		(usrVal.Type().Elem().Kind() != reflect.Float32 && usrVal.Type().Elem().Kind() != reflect.Float64) {
+		return nil, fmt.Errorf("Error encoding field %s: the vector option needs a slice of floats, not %v", name, usrVal.Type())
+	}
+	if usrVal.IsNil() {
+		return wrapFcfVal("nullValue", nil), nil
+	}
+	v := make(Vector, usrVal.Len())
+	for i := range v {
+		v[i] = usrVal.Index(i).Float()
+	}
+	return encodeVector(v), nil
+}
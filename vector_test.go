@@ -0,0 +1,145 @@
+package fcf
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func vectorValue(values ...interface{}) map[string]interface{} {
+	return map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{
+		"__type__": map[string]interface{}{"stringValue": "__vector__"},
+		"value":    map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}},
+	}}}
+}
+
+func TestVector(t *testing.T) {
+	embedding := vectorValue(
+		map[string]interface{}{"doubleValue": 0.5},
+		map[string]interface{}{"doubleValue": -1.25},
+		map[string]interface{}{"integerValue": "2"},
+	)
+	fcfVal := Value{
+		Fields: map[string]interface{}{
+			"Float32": embedding,
+			"Float64": embedding,
+			"Vector":  embedding,
+			"Dynamic": embedding,
+			"Empty":   vectorValue(),
+		},
+	}
+	type doc struct {
+		Float32 []float32
+		Float64 []float64
+		Vector  Vector
+		Dynamic interface{}
+		Empty   Vector
+	}
+	var userVal doc
+	if err := fcfVal.Decode(&userVal); err != nil {
+		t.Fatal(err)
+	}
+	expected := doc{
+		Float32: []float32{0.5, -1.25, 2},
+		Float64: []float64{0.5, -1.25, 2},
+		Vector:  Vector{0.5, -1.25, 2},
+		Dynamic: Vector{0.5, -1.25, 2},
+		Empty:   Vector{},
+	}
+	if !reflect.DeepEqual(userVal, expected) {
+		t.Errorf("expected %+v, got %+v", expected, userVal)
+	}
+
+	// a map that isn't a vector still decodes as a map
+	var m map[string]interface{}
+	notVector := map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{
+		"__type__": map[string]interface{}{"stringValue": "other"},
+	}}}
+	if err := (Value{Fields: map[string]interface{}{"m": notVector}}).Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, map[string]interface{}{"m": map[string]interface{}{"__type__": "other"}}) {
+		t.Errorf("expected a map, got %v", m)
+	}
+}
+
+func TestVectorErrors(t *testing.T) {
+	tests := map[string]interface{}{
+		"element": vectorValue(map[string]interface{}{"stringValue": "x"}),
+		"float32": vectorValue(map[string]interface{}{"doubleValue": 1e39}),
+		"no value": map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{
+			"__type__": map[string]interface{}{"stringValue": "__vector__"},
+		}}},
+	}
+	for name, wrapped := range tests {
+		var userVal struct{ Field []float32 }
+		err := (Value{Fields: map[string]interface{}{"Field": wrapped}}).Decode(&userVal)
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) || decodeErr.Path != "Field" {
+			t.Errorf("%s: expected a *DecodeError for Field, got %v", name, err)
+		}
+	}
+}
+
+func TestEncodeVector(t *testing.T) {
+	fields, err := Encode(struct {
+		Vector Vector
+		Ptr    *Vector
+		Slice  []float64
+	}{
+		Vector: Vector{0.5, math.Inf(1)},
+		Ptr:    &Vector{1},
+		Slice:  []float64{1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"Vector": vectorValue(
+			map[string]interface{}{"doubleValue": 0.5},
+			map[string]interface{}{"doubleValue": "Infinity"},
+		),
+		"Ptr": vectorValue(map[string]interface{}{"doubleValue": 1.0}),
+		"Slice": map[string]interface{}{"arrayValue": map[string]interface{}{"values": []interface{}{
+			map[string]interface{}{"doubleValue": 1.0},
+		}}},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected %v, got %v", expected, fields)
+	}
+}
+
+func TestVectorOption(t *testing.T) {
+	fcfVal := Value{Fields: map[string]interface{}{
+		"emb": vectorValue(
+			map[string]interface{}{"doubleValue": 0.5},
+			map[string]interface{}{"doubleValue": -1.25},
+		),
+		"emb64": vectorValue(map[string]interface{}{"doubleValue": 2.0}),
+		"null":  map[string]interface{}{"nullValue": nil},
+	}}
+	type doc struct {
+		Embedding   []float32  `fcf:"emb,vector"`
+		Embedding64 *[]float64 `fcf:"emb64,vector"`
+		Null        []float32  `fcf:"null,vector"`
+		Omitted     []float64  `fcf:",vector,omitempty"`
+	}
+	var userVal doc
+	if err := fcfVal.Decode(&userVal); err != nil {
+		t.Fatal(err)
+	}
+	fields, err := Encode(userVal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fields, fcfVal.Fields) {
+		t.Errorf("expected %v, got %v", fcfVal.Fields, fields)
+	}
+
+	if _, err := Encode(struct {
+		Field []int `fcf:",vector"`
+	}{[]int{1}}); err == nil {
+		t.Errorf("expected error encoding a slice of ints as a vector")
+	}
+}